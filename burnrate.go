@@ -0,0 +1,72 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+type balanceSample struct {
+	at      time.Time
+	balance float64
+}
+
+// burnRate keeps the balance observations of a wallet within a rolling window
+// and derives the spend rate from them, deposits are not counted as spending
+type burnRate struct {
+	window  time.Duration
+	samples []balanceSample
+}
+
+func newBurnRate(window time.Duration) *burnRate {
+	return &burnRate{window: window}
+}
+
+// Observe records a new balance and drops the samples out of the window
+func (b *burnRate) Observe(at time.Time, balance float64) {
+	b.samples = append(b.samples, balanceSample{at: at, balance: balance})
+
+	var i int
+	for i < len(b.samples)-1 && at.Sub(b.samples[i].at) > b.window {
+		i++
+	}
+	b.samples = b.samples[i:]
+}
+
+// PerHour returns the spent amount per hour within the window
+// it returns false if there are not enough samples
+func (b *burnRate) PerHour() (float64, bool) {
+	if len(b.samples) < 2 {
+		return 0, false
+	}
+
+	elapsed := b.samples[len(b.samples)-1].at.Sub(b.samples[0].at)
+	if elapsed <= 0 {
+		return 0, false
+	}
+
+	var spent float64
+	for i := 1; i < len(b.samples); i++ {
+		if diff := b.samples[i-1].balance - b.samples[i].balance; diff > 0 {
+			spent += diff
+		}
+	}
+	return spent / elapsed.Hours(), true
+}
+
+// SecondsToEmpty returns the projected seconds until the balance runs out,
+// it's +Inf if nothing was spent within the window
+func (b *burnRate) SecondsToEmpty() (float64, bool) {
+	rate, ok := b.PerHour()
+	if !ok {
+		return 0, false
+	}
+
+	balance := b.samples[len(b.samples)-1].balance
+	if balance <= 0 {
+		return 0, true
+	}
+	if rate == 0 {
+		return math.Inf(1), true
+	}
+	return balance / rate * 3600, true
+}
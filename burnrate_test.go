@@ -0,0 +1,85 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBurnRate(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	type sample struct {
+		offset  time.Duration
+		balance float64
+	}
+
+	tests := []struct {
+		name       string
+		window     time.Duration
+		samples    []sample
+		wantRate   float64
+		wantEmpty  float64
+		wantFailed bool
+	}{
+		{
+			name:       "single-sample",
+			window:     time.Hour,
+			samples:    []sample{{0, 10}},
+			wantFailed: true,
+		},
+		{
+			name:      "steady-spending",
+			window:    time.Hour * 6,
+			samples:   []sample{{0, 10}, {time.Hour, 9}, {time.Hour * 2, 8}},
+			wantRate:  1,
+			wantEmpty: 8 * 3600,
+		},
+		{
+			name:      "deposit-ignored",
+			window:    time.Hour * 6,
+			samples:   []sample{{0, 10}, {time.Hour, 8}, {time.Hour * 2, 20}, {time.Hour * 4, 18}},
+			wantRate:  1,
+			wantEmpty: 18 * 3600,
+		},
+		{
+			name:      "out-of-window",
+			window:    time.Hour,
+			samples:   []sample{{0, 100}, {time.Hour, 10}, {time.Hour * 2, 8}},
+			wantRate:  2,
+			wantEmpty: 4 * 3600,
+		},
+		{
+			name:      "no-spending",
+			window:    time.Hour,
+			samples:   []sample{{0, 10}, {time.Minute, 10}},
+			wantRate:  0,
+			wantEmpty: math.Inf(1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBurnRate(tt.window)
+			for _, s := range tt.samples {
+				b.Observe(start.Add(s.offset), s.balance)
+			}
+
+			rate, ok := b.PerHour()
+			if ok == tt.wantFailed {
+				t.Fatalf("burnRate.PerHour() ok = %v, wantFailed %v", ok, tt.wantFailed)
+			}
+			if !ok {
+				return
+			}
+			if rate != tt.wantRate {
+				t.Errorf("burnRate.PerHour() = %v, want %v", rate, tt.wantRate)
+			}
+
+			empty, _ := b.SecondsToEmpty()
+			if empty != tt.wantEmpty {
+				t.Errorf("burnRate.SecondsToEmpty() = %v, want %v", empty, tt.wantEmpty)
+			}
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/metis-devops/metis-sequencer-exporter/internal/collector"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/ethrpc"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/logging"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/themis"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/transport"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	checkOK   = "OK"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+)
+
+type checkResult struct {
+	Network  string
+	Target   string
+	Endpoint string
+	Status   string
+	Latency  time.Duration
+	Head     string
+	Err      error
+}
+
+type checkProbe struct {
+	network  string
+	target   string
+	endpoint string
+	// optional reports whether the error is a warning rather than a failure
+	optional func(err error) bool
+	probe    func(ctx context.Context) (string, error)
+}
+
+func checkCommand(args []string) int {
+	var (
+		confPath string
+		timeout  time.Duration
+	)
+
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fs.StringVar(&confPath, "config", "config.yaml", "config path")
+	fs.DurationVar(&timeout, "timeout", time.Second*30, "timeout of each probe")
+	_ = fs.Parse(args)
+
+	conf, err := config.Parse(confPath)
+	if err != nil {
+		slog.Error("config", "path", confPath, "err", err)
+		return 1
+	}
+
+	// the results are printed as a table, so the connection logs of the metrics are muted
+	if err := logging.Setup(slog.LevelError, logging.FormatText, nil); err != nil {
+		slog.Error("logging", "err", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := runChecks(ctx, conf, timeout)
+	if err := printChecks(os.Stdout, results); err != nil {
+		slog.Error("print results", "err", err)
+		return 1
+	}
+
+	for _, res := range results {
+		if res.Status == checkFail {
+			return 1
+		}
+	}
+	return 0
+}
+
+// runChecks probes every configured endpoint with the probes of the exporter,
+// the probes of a stage run concurrently and the wallet balances wait for the mpc addresses
+func runChecks(basectx context.Context, conf *config.Config, timeout time.Duration) []*checkResult {
+	var results []*checkResult
+	for _, network := range utils.SortedKeys(conf.Networks) {
+		stages, err := networkChecks(network, conf.Networks[network])
+		if err != nil {
+			results = append(results, &checkResult{Network: network, Target: "config", Status: checkFail, Err: err})
+			continue
+		}
+		for _, probes := range stages {
+			results = append(results, runProbes(basectx, probes, timeout)...)
+		}
+	}
+	return results
+}
+
+func runProbes(basectx context.Context, probes []*checkProbe, timeout time.Duration) []*checkResult {
+	results := make([]*checkResult, len(probes))
+	var wg sync.WaitGroup
+	for i, p := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(basectx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			head, err := p.probe(ctx)
+			if errors.Is(err, collector.ErrSkipped) {
+				head, err = "nothing to scrape", nil
+			}
+			res := &checkResult{
+				Network:  p.network,
+				Target:   p.target,
+				Endpoint: p.endpoint,
+				Status:   checkOK,
+				Latency:  time.Since(start),
+				Head:     head,
+				Err:      err,
+			}
+			if err != nil {
+				res.Status = checkFail
+				if p.optional != nil && p.optional(err) {
+					res.Status = checkWarn
+				}
+			}
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// networkChecks returns the probes of the network in stages, they're the probes of the exporter
+// on a scratch registry, so the clients are built and the endpoints are read in the same way
+func networkChecks(network string, conf *config.Network) ([][]*checkProbe, error) {
+	reg := prometheus.NewRegistry()
+	// the probes are not retried, and the breakers never open, so every probe reports its own error
+	pool := transport.NewPool(reg, transport.Options{FailureThreshold: math.MaxInt})
+
+	seqMetric, err := NewSeqMetric(reg, network, conf, pool)
+	if err != nil {
+		return nil, err
+	}
+	probes := sequencerChecks(network, conf, seqMetric)
+
+	walletMetric, err := NewWalletMetric(reg, network, conf, pool, time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	if walletMetric == nil {
+		return [][]*checkProbe{probes}, nil
+	}
+	probes = append(probes, walletEndpointChecks(network, conf.Wallet, walletMetric)...)
+	return [][]*checkProbe{probes, walletChecks(network, walletMetric)}, nil
+}
+
+func sequencerChecks(network string, conf *config.Network, m *SequencerMetric) []*checkProbe {
+	var probes []*checkProbe
+	for _, p := range m.Probes(0) {
+		svc := strings.TrimPrefix(p.Name(), "sequencer/")
+		for _, target := range p.Targets() {
+			if !target.Options.Enabled {
+				continue
+			}
+			ep, client := conf.Sequencers[target.Instance], m.clients[target.Instance]
+			endpoint := ep.L1DTL
+			switch svc {
+			case "l2geth":
+				endpoint = ep.L2Geth
+			case "themis":
+				endpoint = ep.Themis
+			}
+			probes = append(probes, &checkProbe{
+				network:  network,
+				target:   fmt.Sprintf("seq/%s/%s", target.Instance, svc),
+				endpoint: endpoint,
+				probe: func(ctx context.Context) (string, error) {
+					if err := p.Scrape(ctx, target); err != nil {
+						return "", err
+					}
+					client.mutex.Lock()
+					defer client.mutex.Unlock()
+					if svc == "stateroot" {
+						return fmt.Sprintf("next batch %d", client.nextStateRootBatch), nil
+					}
+					return strconv.FormatFloat(client.lastHeights[svc], 'f', -1, 64), nil
+				},
+			})
+		}
+	}
+	// the targets of a probe are in the order of the sequencer map, they're printed by the sequencer
+	slices.SortStableFunc(probes, func(a, b *checkProbe) int { return strings.Compare(a.target, b.target) })
+	return probes
+}
+
+// walletEndpointChecks probes every failover url by its index, so a broken backup is found before it's needed,
+// and resolves the mpc addresses which the wallet probes read
+func walletEndpointChecks(network string, conf *config.Wallet, m *WalletMetric) []*checkProbe {
+	var probes []*checkProbe
+	for _, target := range []struct {
+		name   string
+		urls   config.URLs
+		client *ethrpc.Client
+	}{
+		{"wallet/l1geth", conf.L1Geth, m.l1rpc},
+		{"wallet/l2geth", conf.L2Geth, m.l2rpc},
+	} {
+		for i, url := range target.urls {
+			probes = append(probes, &checkProbe{
+				network:  network,
+				target:   fmt.Sprintf("%s/%d", target.name, i),
+				endpoint: url,
+				probe: func(ctx context.Context) (string, error) {
+					head, err := target.client.Ping(ctx, i)
+					if err != nil {
+						return "", err
+					}
+					return strconv.FormatUint(head, 10), nil
+				},
+			})
+		}
+	}
+
+	if m.themis == nil {
+		return probes
+	}
+	for i := themis.CommonMpcAddr; i <= themis.BlobSubmitMpcAddr; i++ {
+		probes = append(probes, &checkProbe{
+			network:  network,
+			target:   fmt.Sprintf("wallet/mpc/%s", i),
+			endpoint: conf.Themis,
+			// themis may not have the blob submitter, but the other errors fail the check
+			optional: func(err error) bool {
+				return i == themis.BlobSubmitMpcAddr && errors.Is(err, themis.ErrNotFound)
+			},
+			probe: func(ctx context.Context) (string, error) {
+				if err := m.resolveMpcAddr(ctx, i); err != nil {
+					return "", err
+				}
+				m.mutex.Lock()
+				defer m.mutex.Unlock()
+				return m.l1Wallets[i.String()].Hex(), nil
+			},
+		})
+	}
+	return probes
+}
+
+// walletChecks runs the wallet probes once, the balances are read with the resolved mpc addresses
+func walletChecks(network string, m *WalletMetric) []*checkProbe {
+	var probes []*checkProbe
+	for _, p := range m.Probes(0) {
+		svc := strings.TrimPrefix(p.Name(), "wallet/")
+		rpc := m.l1rpc
+		if svc == "l2geth" {
+			rpc = m.l2rpc
+		}
+		for _, target := range p.Targets() {
+			if !target.Options.Enabled {
+				continue
+			}
+			name := "wallet/" + svc
+			if target.Instance != "" {
+				name += "/" + target.Instance
+			}
+			probes = append(probes, &checkProbe{
+				network:  network,
+				target:   name,
+				endpoint: rpc.Active(),
+				probe: func(ctx context.Context) (string, error) {
+					if err := p.Scrape(ctx, target); err != nil {
+						return "", err
+					}
+					switch svc {
+					case "txs":
+						return fmt.Sprintf("block %d", m.txs.lastBlock), nil
+					case "rollup":
+						return fmt.Sprintf("%s elements", gaugeValue(m.rollup.totalElements.WithLabelValues(target.Instance))), nil
+					}
+					wallets := m.l1Wallets
+					if svc == "l2geth" {
+						wallets = m.l2Wallets
+					}
+					return fmt.Sprintf("%d wallets", len(m.copyWallets(wallets))), nil
+				},
+			})
+		}
+	}
+	return probes
+}
+
+// gaugeValue returns the value of the gauge as a decimal
+func gaugeValue(g prometheus.Gauge) string {
+	var metric dto.Metric
+	if err := g.Write(&metric); err != nil {
+		return ""
+	}
+	return strconv.FormatFloat(metric.GetGauge().GetValue(), 'f', -1, 64)
+}
+
+func printChecks(w io.Writer, results []*checkResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NETWORK\tTARGET\tENDPOINT\tSTATUS\tLATENCY\tHEAD\tERROR") //nolint:errcheck
+	for _, res := range results {
+		var errText string
+		if res.Err != nil {
+			errText = utils.RedactURLs(res.Err.Error())
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", //nolint:errcheck
+			res.Network, res.Target, utils.RedactURL(res.Endpoint), res.Status, res.Latency.Round(time.Millisecond), res.Head, errText)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/themis"
+)
+
+func TestRunChecks(t *testing.T) {
+	dtlServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/highest/l1" {
+			t.Errorf("expected url path /highest/l1 got url path %s", r.URL.Path)
+			return
+		}
+		_, _ = w.Write([]byte(`{"blockNumber": 100}`))
+	}))
+	defer dtlServer.Close()
+
+	deadServer := httptest.NewServer(http.NotFoundHandler())
+	deadServer.Close()
+
+	disabled := false
+	conf := &config.Config{Networks: map[string]*config.Network{
+		"mainnet": {
+			Sequencers: map[string]*config.Sequencer{
+				"node-0": {
+					L2Geth: deadServer.URL,
+					L1DTL:  dtlServer.URL,
+					Scrape: &config.SequencerScrape{StateRoot: &config.Scrape{Enabled: &disabled}},
+				},
+			},
+		},
+	}}
+
+	results := runChecks(context.Background(), conf, time.Second*5)
+	if len(results) != 2 {
+		t.Fatalf("runChecks() returns %d results, want 2", len(results))
+	}
+
+	want := map[string]string{"seq/node-0/l2geth": checkFail, "seq/node-0/l1dtl": checkOK}
+	for _, res := range results {
+		if res.Status != want[res.Target] {
+			t.Errorf("runChecks() %s status = %s, want %s (err %v)", res.Target, res.Status, want[res.Target], res.Err)
+		}
+		if res.Target == "seq/node-0/l1dtl" && res.Head != "100" {
+			t.Errorf("runChecks() %s head = %s, want 100", res.Target, res.Head)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := printChecks(&buf, results); err != nil {
+		t.Fatalf("printChecks() error = %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 3 {
+		t.Errorf("printChecks() prints %d lines, want 3", len(lines))
+	}
+}
+
+func TestRunChecks_Mpc(t *testing.T) {
+	deadServer := httptest.NewServer(http.NotFoundHandler())
+	deadServer.Close()
+
+	tests := []struct {
+		name string
+		// status is the status of the blob submit mpc address, the others are found
+		status int
+		// unreachable closes the themis server
+		unreachable bool
+		want        map[themis.MpcAddrType]string
+	}{
+		{
+			name:        "unreachable",
+			unreachable: true,
+			want: map[themis.MpcAddrType]string{
+				themis.CommonMpcAddr:       checkFail,
+				themis.StateSubmitMpcAddr:  checkFail,
+				themis.RewardSubmitMpcAddr: checkFail,
+				themis.BlobSubmitMpcAddr:   checkFail,
+			},
+		},
+		{
+			name:   "not-found",
+			status: http.StatusNotFound,
+			want: map[themis.MpcAddrType]string{
+				themis.CommonMpcAddr:       checkOK,
+				themis.StateSubmitMpcAddr:  checkOK,
+				themis.RewardSubmitMpcAddr: checkOK,
+				themis.BlobSubmitMpcAddr:   checkWarn,
+			},
+		},
+		{
+			name:   "server-error",
+			status: http.StatusInternalServerError,
+			want: map[themis.MpcAddrType]string{
+				themis.CommonMpcAddr:       checkOK,
+				themis.StateSubmitMpcAddr:  checkOK,
+				themis.RewardSubmitMpcAddr: checkOK,
+				themis.BlobSubmitMpcAddr:   checkFail,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			themisServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var addrType themis.MpcAddrType
+				if _, err := fmt.Sscanf(r.URL.Path, "/mpc/latest/%d", &addrType); err != nil {
+					t.Errorf("unexpected url path %s", r.URL.Path)
+					return
+				}
+				if addrType == themis.BlobSubmitMpcAddr {
+					w.WriteHeader(tt.status)
+					_, _ = w.Write([]byte(`{"error": "error"}`))
+					return
+				}
+				_, _ = fmt.Fprintf(w, `{"height": "1", "result": {"mpc_id": "id", "mpc_address": "0x%040x", "mpc_type": %d}}`, addrType+1, addrType)
+			}))
+			defer themisServer.Close()
+			if tt.unreachable {
+				themisServer.Close()
+			}
+
+			conf := &config.Config{Networks: map[string]*config.Network{
+				"mainnet": {
+					Wallet: &config.Wallet{
+						Themis: themisServer.URL,
+						L1Geth: config.URLs{deadServer.URL},
+						L2Geth: config.URLs{deadServer.URL},
+					},
+				},
+			}}
+
+			got := make(map[string]*checkResult)
+			for _, res := range runChecks(context.Background(), conf, time.Second*5) {
+				got[res.Target] = res
+			}
+
+			if res := got["wallet/l1geth/0"]; res == nil || res.Status != checkFail {
+				t.Errorf("runChecks() wallet/l1geth/0 = %+v, want %s", res, checkFail)
+			}
+			for addrType, status := range tt.want {
+				res := got["wallet/mpc/"+addrType.String()]
+				if res == nil {
+					t.Errorf("runChecks() %s is missing", addrType)
+					continue
+				}
+				if res.Status != status {
+					t.Errorf("runChecks() %s status = %s, want %s (err %v)", addrType, res.Status, status, res.Err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+type state struct {
+	alert        *Alert
+	firing       bool
+	lastNotified time.Time
+}
+
+// Engine evaluates the rules against the metrics of the exporter and notifies the webhooks,
+// an alert is firing once its condition holds for the rule duration and it's notified again
+// after the repeat interval until it's resolved. The alerts which failed to be notified are
+// notified again in the next evaluation, so a receiver may get the same alert more than once.
+type Engine struct {
+	gatherer       prometheus.Gatherer
+	rules          []*rule
+	receivers      []*webhook
+	interval       time.Duration
+	repeatInterval time.Duration
+	states         map[string]*state
+	// the resolved alerts which are not notified yet
+	resolved map[string]*Alert
+	logger   *slog.Logger
+}
+
+func NewEngine(conf *config.Alerting, gatherer prometheus.Gatherer) (*Engine, error) {
+	e := &Engine{
+		gatherer:       gatherer,
+		interval:       time.Duration(conf.Interval),
+		repeatInterval: time.Duration(conf.RepeatInterval),
+		states:         make(map[string]*state),
+		resolved:       make(map[string]*Alert),
+		logger:         logging.New("alert"),
+	}
+	if e.interval <= 0 {
+		e.interval = 30 * time.Second
+	}
+	if e.repeatInterval <= 0 {
+		e.repeatInterval = 4 * time.Hour
+	}
+
+	for _, rc := range conf.Rules {
+		r, err := newRule(rc)
+		if err != nil {
+			return nil, err
+		}
+		e.rules = append(e.rules, r)
+	}
+
+	for _, rc := range conf.Receivers {
+		w, err := newWebhook(rc)
+		if err != nil {
+			return nil, err
+		}
+		e.receivers = append(e.receivers, w)
+	}
+	return e, nil
+}
+
+// Run evaluates the rules periodically until the context is done
+func (e *Engine) Run(basectx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-basectx.Done():
+			return
+		case now := <-ticker.C:
+			notification, err := e.Evaluate(now)
+			if err != nil {
+				e.logger.Error("evaluate rules", "err", err)
+				continue
+			}
+			if notification == nil {
+				continue
+			}
+
+			newctx, cancel := context.WithTimeout(basectx, e.interval)
+			if err := e.notify(newctx, notification); err == nil {
+				e.Notified(notification, now)
+			}
+			cancel()
+		}
+	}
+}
+
+// Evaluate updates the alert states and returns the alerts to notify, nil if nothing changed,
+// Notified should be called once the notification is delivered
+func (e *Engine) Evaluate(now time.Time) (*Notification, error) {
+	gathered, err := e.gatherer.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("gather: %w", err)
+	}
+
+	families := make(map[string]*dto.MetricFamily, len(gathered))
+	for _, family := range gathered {
+		families[family.GetName()] = family
+	}
+
+	var (
+		alerts []*Alert
+		active = make(map[string]bool)
+	)
+
+	for _, r := range e.rules {
+		for _, c := range r.evaluate(families, now) {
+			key := fingerprint(r.conf.Name, c.labels)
+			active[key] = true
+
+			st, ok := e.states[key]
+			if !ok {
+				st = &state{alert: &Alert{
+					Status:   StatusFiring,
+					Name:     r.conf.Name,
+					Severity: r.conf.Severity,
+					Labels:   c.labels,
+					StartsAt: now,
+				}}
+				e.states[key] = st
+			}
+			st.alert.Value = c.value
+			st.alert.Summary = c.summary
+
+			switch {
+			case !st.firing && now.Sub(st.alert.StartsAt) >= time.Duration(r.conf.For):
+				st.firing = true
+			case st.firing && now.Sub(st.lastNotified) >= e.repeatInterval:
+			default:
+				continue
+			}
+
+			alerts = append(alerts, st.alert)
+		}
+	}
+
+	for key, st := range e.states {
+		if active[key] {
+			continue
+		}
+		delete(e.states, key)
+		if st.firing {
+			st.alert.Status = StatusResolved
+			st.alert.EndsAt = now
+			e.resolved[key] = st.alert
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(e.resolved)) {
+		alerts = append(alerts, e.resolved[key])
+	}
+
+	if len(alerts) == 0 {
+		return nil, nil
+	}
+
+	notification := &Notification{Status: StatusResolved, Alerts: alerts}
+	for _, a := range alerts {
+		if a.Status == StatusFiring {
+			notification.Status = StatusFiring
+			break
+		}
+	}
+	return notification, nil
+}
+
+// Notified records the delivery of the notification, the firing alerts are notified again after
+// the repeat interval, and the resolved ones are done
+func (e *Engine) Notified(n *Notification, now time.Time) {
+	for _, a := range n.Alerts {
+		key := fingerprint(a.Name, a.Labels)
+		if a.Status == StatusResolved {
+			if e.resolved[key] == a {
+				delete(e.resolved, key)
+			}
+			continue
+		}
+		if st, ok := e.states[key]; ok && st.alert == a {
+			st.lastNotified = now
+		}
+	}
+}
+
+// notify sends the notification to every receiver, it fails if any receiver fails
+func (e *Engine) notify(ctx context.Context, n *Notification) error {
+	for _, a := range n.Alerts {
+		e.logger.Warn("alert", "status", a.Status, "name", a.Name, "labels", a.Labels, "summary", a.Summary)
+	}
+
+	var errs []error
+	for _, w := range e.receivers {
+		if err := w.Send(ctx, n); err != nil {
+			e.logger.Error("notify", "receiver", w.conf.Name, "err", err)
+			errs = append(errs, fmt.Errorf("%s: %w", w.conf.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,215 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestEngine_Evaluate(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	balance := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: balanceMetric, Help: "balance"}, []string{"chain", "alias"})
+	balance.WithLabelValues("eth", "CommonMpcAddr").Set(1)
+	balance.WithLabelValues("eth", "Custom").Set(1)
+
+	height := prometheus.NewCounterVec(prometheus.CounterOpts{Name: heightMetric, Help: "height"}, []string{"svc_name", "seq_name"})
+	height.WithLabelValues("l2geth", "node-0").Add(100)
+
+	reg.MustRegister(balance, height)
+
+	engine, err := NewEngine(&config.Alerting{
+		RepeatInterval: config.Duration(time.Hour),
+		Rules: []*config.AlertRule{
+			{
+				Name:      "WalletLow",
+				Type:      WalletBalance,
+				Threshold: 3,
+				For:       config.Duration(time.Minute),
+				Matchers:  map[string]string{"alias": "CommonMpcAddr"},
+			},
+			{
+				Name:   "ChainStalled",
+				Type:   ChainStalled,
+				Window: config.Duration(2 * time.Minute),
+			},
+		},
+	}, reg)
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+
+	start := time.Unix(1700000000, 0)
+	// failed is a notification which is not delivered, so it's notified again in the next evaluation
+	steps := []struct {
+		offset time.Duration
+		update func()
+		want   []string
+		failed bool
+	}{
+		{offset: 0},
+		{offset: time.Minute, want: []string{"firing WalletLow"}},
+		{offset: 2 * time.Minute, update: func() { height.WithLabelValues("l2geth", "node-0").Add(1) }},
+		{offset: 4 * time.Minute, want: []string{"firing ChainStalled"}},
+		{offset: 5 * time.Minute, update: func() { height.WithLabelValues("l2geth", "node-0").Add(1) }, want: []string{"resolved ChainStalled"}, failed: true},
+		{offset: 6 * time.Minute, want: []string{"resolved ChainStalled"}},
+		{offset: 7 * time.Minute, update: func() { height.WithLabelValues("l2geth", "node-0").Add(1) }},
+		{offset: 61 * time.Minute, update: func() { height.WithLabelValues("l2geth", "node-0").Add(1) }, want: []string{"firing WalletLow"}, failed: true},
+		{offset: 62 * time.Minute, update: func() { height.WithLabelValues("l2geth", "node-0").Add(1) }, want: []string{"firing WalletLow"}},
+		{offset: 63 * time.Minute, update: func() { balance.WithLabelValues("eth", "CommonMpcAddr").Set(10) }, want: []string{"resolved WalletLow"}},
+	}
+
+	for _, step := range steps {
+		if step.update != nil {
+			step.update()
+		}
+
+		n, err := engine.Evaluate(start.Add(step.offset))
+		if err != nil {
+			t.Fatalf("Engine.Evaluate() error = %v", err)
+		}
+
+		var got []string
+		if n != nil {
+			for _, a := range n.Alerts {
+				got = append(got, a.Status+" "+a.Name)
+			}
+			if !step.failed {
+				engine.Notified(n, start.Add(step.offset))
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(step.want, ",") {
+			t.Errorf("Engine.Evaluate() at %s = %v, want %v", step.offset, got, step.want)
+		}
+	}
+}
+
+func TestRule_PruneHistory(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	height := prometheus.NewCounterVec(prometheus.CounterOpts{Name: heightMetric, Help: "height"}, []string{"svc_name", "seq_name"})
+	reg.MustRegister(height)
+
+	r, err := newRule(&config.AlertRule{Name: "ChainStalled", Type: ChainStalled})
+	if err != nil {
+		t.Fatalf("newRule() error = %v", err)
+	}
+
+	start := time.Unix(1700000000, 0)
+	evaluate := func(offset time.Duration) {
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("Gather() error = %v", err)
+		}
+		byName := make(map[string]*dto.MetricFamily, len(families))
+		for _, family := range families {
+			byName[family.GetName()] = family
+		}
+		r.evaluate(byName, start.Add(offset))
+	}
+
+	height.WithLabelValues("l2geth", "node-0").Add(1)
+	height.WithLabelValues("l2geth", "node-1").Add(1)
+	evaluate(0)
+	if len(r.history) != 2 {
+		t.Fatalf("rule.history has %d series, want 2", len(r.history))
+	}
+
+	height.DeleteLabelValues("l2geth", "node-1")
+	evaluate(time.Minute)
+	if _, ok := r.history[fingerprint("", map[string]string{"svc_name": "l2geth", "seq_name": "node-1"})]; ok || len(r.history) != 1 {
+		t.Errorf("rule.history = %v, want the history of node-0 only", r.history)
+	}
+}
+
+func TestText(t *testing.T) {
+	text := templateFuncs["text"].(func(*Notification) string)
+
+	// the summary of the multi-byte runes is cut in the middle of a rune by the byte length
+	n := &Notification{Alerts: []*Alert{{Status: StatusFiring, Name: "WalletLo", Summary: strings.Repeat("é", maxTextLength)}}}
+	got := text(n)
+	if !utf8.ValidString(got) {
+		t.Errorf("text() is not valid utf-8")
+	}
+	if len(got) > maxTextLength || !strings.HasSuffix(got, "...") {
+		t.Errorf("text() length = %d, want it to be truncated to %d", len(got), maxTextLength)
+	}
+}
+
+func TestWebhook_Send(t *testing.T) {
+	notification := &Notification{
+		Status: StatusFiring,
+		Alerts: []*Alert{
+			{Status: StatusFiring, Name: "WalletLow", Severity: "high", Summary: "The balance is low"},
+			{Status: StatusResolved, Name: "ChainStalled", Severity: "critical", Summary: `"quoted"`},
+		},
+	}
+
+	tests := []struct {
+		format string
+		check  func(t *testing.T, body map[string]any)
+	}{
+		{
+			format: "json",
+			check: func(t *testing.T, body map[string]any) {
+				if body["status"] != StatusFiring || len(body["alerts"].([]any)) != 2 {
+					t.Errorf("unexpected json payload %v", body)
+				}
+			},
+		},
+		{
+			format: "slack",
+			check: func(t *testing.T, body map[string]any) {
+				want := "[FIRING] WalletLow (high): The balance is low\n[RESOLVED] ChainStalled (critical): \"quoted\""
+				if body["text"] != want {
+					t.Errorf("unexpected slack payload %q, want %q", body["text"], want)
+				}
+			},
+		},
+		{
+			format: "discord",
+			check: func(t *testing.T, body map[string]any) {
+				if _, ok := body["content"].(string); !ok {
+					t.Errorf("unexpected discord payload %v", body)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if header := r.Header.Get("Authorization"); header != "Bearer token" {
+					t.Errorf("expected the authorization of the client config but got %q", header)
+				}
+				var body map[string]any
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("couldn't decode the payload %s", err)
+					return
+				}
+				tt.check(t, body)
+			}))
+			defer server.Close()
+
+			w, err := newWebhook(&config.AlertReceiver{
+				Name:   tt.format,
+				URL:    server.URL,
+				Format: tt.format,
+				Client: &config.HTTPClient{BearerToken: "token"},
+			})
+			if err != nil {
+				t.Fatalf("newWebhook() error = %v", err)
+			}
+			if err := w.Send(context.Background(), notification); err != nil {
+				t.Errorf("webhook.Send() error = %v", err)
+			}
+		})
+	}
+}
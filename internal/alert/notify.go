@@ -0,0 +1,149 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/transport"
+)
+
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+type Alert struct {
+	Status   string            `json:"status"`
+	Name     string            `json:"name"`
+	Severity string            `json:"severity,omitempty"`
+	Labels   map[string]string `json:"labels"`
+	Value    float64           `json:"value"`
+	Summary  string            `json:"summary"`
+	StartsAt time.Time         `json:"startsAt"`
+	EndsAt   time.Time         `json:"endsAt,omitempty"`
+}
+
+// Notification is the payload of the generic JSON webhook and the data of the templates
+type Notification struct {
+	Status string   `json:"status"`
+	Alerts []*Alert `json:"alerts"`
+}
+
+// the built-in payload templates of the slack and discord incoming webhooks
+const (
+	slackTemplate   = `{"text": {{ text . | json }}}`
+	discordTemplate = `{"content": {{ text . | json }}}`
+)
+
+// maxTextLength is the max length of the message text, discord rejects longer content
+const maxTextLength = 2000
+
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"text": func(n *Notification) string {
+		lines := make([]string, 0, len(n.Alerts))
+		for _, a := range n.Alerts {
+			lines = append(lines, fmt.Sprintf("[%s] %s (%s): %s", strings.ToUpper(a.Status), a.Name, a.Severity, a.Summary))
+		}
+		text := strings.Join(lines, "\n")
+		if len(text) > maxTextLength {
+			// cut on a rune boundary, so the text is still valid utf-8
+			cut := maxTextLength - 3
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			text = text[:cut] + "..."
+		}
+		return text
+	},
+}
+
+type webhook struct {
+	conf       *config.AlertReceiver
+	tmpl       *template.Template
+	httpClient *http.Client
+}
+
+func newWebhook(conf *config.AlertReceiver) (*webhook, error) {
+	text := conf.Template
+	if text == "" {
+		switch conf.Format {
+		case "", config.ReceiverJSON:
+		case config.ReceiverSlack:
+			text = slackTemplate
+		case config.ReceiverDiscord:
+			text = discordTemplate
+		default:
+			return nil, fmt.Errorf("receiver %s: unknown format %q", conf.Name, conf.Format)
+		}
+	}
+
+	httpClient, err := transport.NewHTTPClient(conf.Client)
+	if err != nil {
+		return nil, fmt.Errorf("receiver %s: %w", conf.Name, err)
+	}
+	httpClient.Timeout = 30 * time.Second
+
+	w := &webhook{conf: conf, httpClient: httpClient}
+	if text != "" {
+		tmpl, err := template.New(conf.Name).Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("receiver %s: %w", conf.Name, err)
+		}
+		w.tmpl = tmpl
+	}
+	return w, nil
+}
+
+func (w *webhook) payload(n *Notification) ([]byte, error) {
+	if w.tmpl == nil {
+		return json.Marshal(n)
+	}
+
+	var buf bytes.Buffer
+	if err := w.tmpl.Execute(&buf, n); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (w *webhook) Send(ctx context.Context, n *Notification) error {
+	payload, err := w.payload(n)
+	if err != nil {
+		return fmt.Errorf("render payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.conf.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.conf.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook error: code %d msg %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
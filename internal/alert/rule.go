@@ -0,0 +1,209 @@
+package alert
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	ChainStalled   = config.AlertChainStalled
+	WalletBalance  = config.AlertWalletBalance
+	ScrapeFailures = config.AlertScrapeFailures
+	SpanEnding     = config.AlertSpanEnding
+	NonceGap       = config.AlertNonceGap
+)
+
+// the metric names which the rules are evaluated against
+const (
+	heightMetric        = "metis:sequencer:height"
+	balanceMetric       = "metis:sequencer:wallet:balance"
+	failuresMetric      = "metis_sequencer_exporter_failures"
+	spanRemainingMetric = "metis:sequencer:span:remaining_blocks"
+	nonceGapMetric      = "metis:sequencer:wallet:nonce_gap"
+)
+
+type sample struct {
+	labels map[string]string
+	value  float64
+}
+
+type point struct {
+	at    time.Time
+	value float64
+}
+
+type candidate struct {
+	labels  map[string]string
+	value   float64
+	summary string
+}
+
+type rule struct {
+	conf    *config.AlertRule
+	window  time.Duration
+	history map[string][]point
+}
+
+func newRule(conf *config.AlertRule) (*rule, error) {
+	r := &rule{conf: conf, window: time.Duration(conf.Window), history: make(map[string][]point)}
+
+	switch conf.Type {
+	case ChainStalled:
+		if r.window <= 0 {
+			r.window = 2 * time.Minute
+		}
+	case ScrapeFailures:
+		if r.window <= 0 {
+			r.window = time.Minute
+		}
+	case WalletBalance, SpanEnding, NonceGap:
+	default:
+		return nil, fmt.Errorf("rule %s: unknown type %q", conf.Name, conf.Type)
+	}
+
+	if conf.Name == "" {
+		return nil, fmt.Errorf("rule of type %s has no name", conf.Type)
+	}
+	return r, nil
+}
+
+func (r *rule) evaluate(families map[string]*dto.MetricFamily, now time.Time) []*candidate {
+	var res []*candidate
+	switch r.conf.Type {
+	case ChainStalled:
+		samples := r.samples(families, heightMetric)
+		r.prune(samples)
+		for _, s := range samples {
+			increase, ok := r.increase(s, now)
+			if ok && increase == 0 {
+				res = append(res, &candidate{labels: s.labels, value: s.value, summary: fmt.Sprintf(
+					"%s of %s has no new blocks in the past %s", s.labels["svc_name"], s.labels["seq_name"], r.window)})
+			}
+		}
+	case ScrapeFailures:
+		samples := r.samples(families, failuresMetric)
+		r.prune(samples)
+		for _, s := range samples {
+			increase, ok := r.increase(s, now)
+			if ok && increase > r.conf.Threshold {
+				res = append(res, &candidate{labels: s.labels, value: increase, summary: fmt.Sprintf(
+					"%s has %v scrape failures in the past %s", s.labels["svc_name"], increase, r.window)})
+			}
+		}
+	case WalletBalance:
+		for _, s := range r.samples(families, balanceMetric) {
+			if s.value < r.conf.Threshold {
+				res = append(res, &candidate{labels: s.labels, value: s.value, summary: fmt.Sprintf(
+					"The balance of %s on %s is %v, less than %v", s.labels["alias"], s.labels["chain"], s.value, r.conf.Threshold)})
+			}
+		}
+	case SpanEnding:
+		for _, s := range r.samples(families, spanRemainingMetric) {
+			if s.value < r.conf.Threshold {
+				res = append(res, &candidate{labels: s.labels, value: s.value, summary: fmt.Sprintf(
+					"The span of %s ends in %v blocks", s.labels["seq_name"], s.value)})
+			}
+		}
+	case NonceGap:
+		for _, s := range r.samples(families, nonceGapMetric) {
+			if s.value > r.conf.Threshold {
+				res = append(res, &candidate{labels: s.labels, value: s.value, summary: fmt.Sprintf(
+					"%s on %s has %v pending transactions", s.labels["alias"], s.labels["chain"], s.value)})
+			}
+		}
+	}
+	return res
+}
+
+// samples returns the samples of the metric which match the rule matchers
+func (r *rule) samples(families map[string]*dto.MetricFamily, name string) []*sample {
+	family, ok := families[name]
+	if !ok {
+		return nil
+	}
+
+	var res []*sample
+next:
+	for _, m := range family.GetMetric() {
+		labels := make(map[string]string, len(m.GetLabel()))
+		for _, label := range m.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		for key, value := range r.conf.Matchers {
+			if labels[key] != value {
+				continue next
+			}
+		}
+
+		var value float64
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			value = m.GetCounter().GetValue()
+		case dto.MetricType_GAUGE:
+			value = m.GetGauge().GetValue()
+		default:
+			value = m.GetUntyped().GetValue()
+		}
+		res = append(res, &sample{labels: labels, value: value})
+	}
+	return res
+}
+
+// increase records the sample and returns its increase within the window,
+// it returns false until the history covers the whole window
+func (r *rule) increase(s *sample, now time.Time) (float64, bool) {
+	key := fingerprint("", s.labels)
+	points := append(r.history[key], point{at: now, value: s.value})
+
+	var i int
+	for i < len(points)-1 && now.Sub(points[i+1].at) >= r.window {
+		i++
+	}
+	points = points[i:]
+	r.history[key] = points
+
+	if now.Sub(points[0].at) < r.window {
+		return 0, false
+	}
+
+	// counters only go down when the exporter restarts
+	increase := s.value - points[0].value
+	if increase < 0 {
+		increase = s.value
+	}
+	return increase, true
+}
+
+// prune drops the history of the series which are not in the samples, e.g. the removed targets,
+// so the history doesn't grow with the series which come and go
+func (r *rule) prune(samples []*sample) {
+	seen := make(map[string]bool, len(samples))
+	for _, s := range samples {
+		seen[fingerprint("", s.labels)] = true
+	}
+	for key := range r.history {
+		if !seen[key] {
+			delete(r.history, key)
+		}
+	}
+}
+
+func fingerprint(name string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "|%s=%s", key, labels[key])
+	}
+	return b.String()
+}
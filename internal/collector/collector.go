@@ -0,0 +1,236 @@
+// Package collector schedules the probes of the exporter, and accounts the failures and the latencies
+// of their scrapes in the same way
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+// MaxStartJitter is the upper bound of the random delay before the first scrape of a target,
+// so the targets of the same interval don't send the requests at once
+const MaxStartJitter = 10 * time.Second
+
+// ErrSkipped is returned by the scrape which has nothing to scrape, it's not counted as a success or a failure
+var ErrSkipped = errors.New("nothing to scrape")
+
+// Target is a scraped instance of a probe, e.g. a sequencer or a contract
+type Target struct {
+	// Name is the svc_name label of the scrape metrics, it's unique in the scheduler
+	Name string
+	// Instance is the key of the target in the probe, e.g. the sequencer name
+	Instance string
+	Options  Options
+}
+
+// Probe scrapes a kind of targets
+type Probe interface {
+	Name() string
+	Targets() []Target
+	// Scrape scrapes the target once, the context is bounded by the timeout of the target
+	Scrape(ctx context.Context, target Target) error
+}
+
+type probe struct {
+	name    string
+	targets []Target
+	scrape  func(ctx context.Context, target Target) error
+}
+
+// NewProbe returns the probe of the targets which are scraped by the function
+func NewProbe(name string, targets []Target, scrape func(ctx context.Context, target Target) error) Probe {
+	return &probe{name: name, targets: targets, scrape: scrape}
+}
+
+func (p *probe) Name() string {
+	return p.name
+}
+
+func (p *probe) Targets() []Target {
+	return p.targets
+}
+
+func (p *probe) Scrape(ctx context.Context, target Target) error {
+	return p.scrape(ctx, target)
+}
+
+// Scheduler runs a scrape loop for every target of the probes
+type Scheduler struct {
+	failures *prometheus.CounterVec
+	up       *prometheus.GaugeVec
+	duration *prometheus.GaugeVec
+	skipped  *prometheus.CounterVec
+	logger   *slog.Logger
+
+	// the concurrent scrapes of the same target share the result of the one in flight
+	group     singleflight.Group
+	maxJitter time.Duration
+}
+
+func NewScheduler(reg prometheus.Registerer, logger *slog.Logger) *Scheduler {
+	s := &Scheduler{
+		failures: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "metis_sequencer_exporter_failures",
+				Help: "Number of scrape errors.",
+			},
+			[]string{"svc_name"},
+		),
+		up: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "metis_sequencer_exporter_up",
+				Help: "Whether the last scrape of the service succeeded, 0 means the service is down.",
+			},
+			[]string{"svc_name"},
+		),
+		duration: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "metis_sequencer_exporter_scrape_duration_seconds",
+				Help: "Duration of the last scrape of the service.",
+			},
+			[]string{"svc_name"},
+		),
+		skipped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "metis_sequencer_exporter_skipped_rounds",
+				Help: "Number of scrape rounds skipped because the previous scrape of the service overran the interval.",
+			},
+			[]string{"svc_name"},
+		),
+		logger:    logger,
+		maxJitter: MaxStartJitter,
+	}
+	reg.MustRegister(s.failures, s.up, s.duration, s.skipped)
+	return s
+}
+
+// Run scrapes the enabled targets of the probes until the context is done
+func (s *Scheduler) Run(basectx context.Context, probes ...Probe) {
+	var wg sync.WaitGroup
+	s.eachTarget(probes, func(p Probe, target Target) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(basectx, p, target)
+		}()
+	})
+	wg.Wait()
+}
+
+// eachTarget calls the function with every enabled target of the probes
+func (s *Scheduler) eachTarget(probes []Probe, fn func(p Probe, target Target)) {
+	for _, p := range probes {
+		for _, target := range p.Targets() {
+			if !target.Options.Enabled {
+				s.logger.Warn("scrape is disabled", "probe", p.Name(), "target", target.Name)
+				continue
+			}
+			fn(p, target)
+		}
+	}
+}
+
+// loop scrapes the target after a random start jitter and then every interval until the context is done,
+// the rounds which are overrun by the previous scrape are skipped rather than queued
+func (s *Scheduler) loop(basectx context.Context, p Probe, target Target) {
+	interval := target.Options.Interval
+	if interval <= 0 {
+		s.logger.Error("scrape interval is not positive", "probe", p.Name(), "target", target.Name, "interval", interval)
+		return
+	}
+
+	next := time.Now()
+	if jitter := min(interval, s.maxJitter); jitter > 0 {
+		next = next.Add(rand.N(jitter))
+	}
+
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-basectx.Done():
+			return
+		case <-timer.C:
+			_ = s.Scrape(basectx, p, target)
+
+			next = next.Add(interval)
+			if late := time.Since(next); late >= 0 {
+				skipped := late/interval + 1
+				next = next.Add(skipped * interval)
+				s.skipped.With(prometheus.Labels{"svc_name": target.Name}).Add(float64(skipped))
+				s.logger.Warn("scrape overran the interval", "probe", p.Name(), "target", target.Name, "skipped", int(skipped))
+			}
+			timer.Reset(time.Until(next))
+		}
+	}
+}
+
+// Scrape scrapes the target once and records the result, the concurrent calls of the same target
+// wait for the scrape in flight instead of sending the requests again
+func (s *Scheduler) Scrape(basectx context.Context, p Probe, target Target) error {
+	_, err, _ := s.group.Do(target.Name, func() (any, error) {
+		return nil, s.scrape(basectx, p, target)
+	})
+	return err
+}
+
+func (s *Scheduler) scrape(basectx context.Context, p Probe, target Target) error {
+	ctx, cancel := context.WithTimeout(basectx, target.Options.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := p.Scrape(ctx, target)
+	duration := time.Since(start)
+
+	if errors.Is(err, ErrSkipped) {
+		s.logger.Debug("Skipped", "probe", p.Name(), "target", target.Name)
+		return err
+	}
+	s.observe(target.Name, duration, err)
+	if err != nil {
+		s.logger.Error("scrape", "probe", p.Name(), "target", target.Name, "err", err)
+		return err
+	}
+	s.logger.Debug("Done", "probe", p.Name(), "target", target.Name, "duration", duration)
+	return nil
+}
+
+// observe records the scrape result of the service
+func (s *Scheduler) observe(svc string, duration time.Duration, err error) {
+	labels := prometheus.Labels{"svc_name": svc}
+	s.duration.With(labels).Set(duration.Seconds())
+	if err != nil {
+		s.failures.With(labels).Inc()
+		s.up.With(labels).Set(0)
+		return
+	}
+	s.up.With(labels).Set(1)
+}
+
+// Parallel calls the function with every name concurrently,
+// and returns the errors of the names which are failed
+func Parallel(names []string, fn func(name string) error) error {
+	errs := make([]error, len(names))
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(name); err != nil {
+				errs[i] = fmt.Errorf("%s: %s", name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,180 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestScheduler_Run(t *testing.T) {
+	opts := Options{Enabled: true, Interval: 10 * time.Millisecond, Timeout: time.Second}
+	disabled := Options{Interval: 10 * time.Millisecond, Timeout: time.Second}
+
+	var scrapes, disabledScrapes atomic.Int32
+	probe := NewProbe("test", []Target{
+		{Name: "ok", Instance: "ok", Options: opts},
+		{Name: "failed", Instance: "failed", Options: opts},
+		{Name: "skipped", Instance: "skipped", Options: opts},
+		{Name: "disabled", Instance: "disabled", Options: disabled},
+	}, func(ctx context.Context, target Target) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Errorf("scrape of %s has no timeout", target.Name)
+		}
+		switch target.Instance {
+		case "failed":
+			return errors.New("unreachable")
+		case "skipped":
+			return ErrSkipped
+		case "disabled":
+			disabledScrapes.Add(1)
+		default:
+			scrapes.Add(1)
+		}
+		return nil
+	})
+
+	reg := prometheus.NewRegistry()
+	s := NewScheduler(reg, slog.Default())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 55*time.Millisecond)
+	defer cancel()
+	s.Run(ctx, probe)
+
+	if got := scrapes.Load(); got < 2 {
+		t.Errorf("scrapes = %d, want it to be scraped every interval", got)
+	}
+	if got := disabledScrapes.Load(); got != 0 {
+		t.Errorf("disabled scrapes = %d, want 0", got)
+	}
+
+	tests := []struct {
+		svc         string
+		wantUp      float64
+		wantFailure bool
+	}{
+		{svc: "ok", wantUp: 1},
+		{svc: "failed", wantUp: 0, wantFailure: true},
+	}
+	for _, tt := range tests {
+		if got := testutil.ToFloat64(s.up.WithLabelValues(tt.svc)); got != tt.wantUp {
+			t.Errorf("up of %s = %v, want %v", tt.svc, got, tt.wantUp)
+		}
+		if got := testutil.ToFloat64(s.failures.WithLabelValues(tt.svc)) > 0; got != tt.wantFailure {
+			t.Errorf("failures of %s > 0 = %v, want %v", tt.svc, got, tt.wantFailure)
+		}
+	}
+
+	// the skipped and the disabled targets have no scrape metrics
+	if got := testutil.CollectAndCount(s.up); got != 2 {
+		t.Errorf("up series = %d, want 2", got)
+	}
+	if got := testutil.CollectAndCount(s.duration); got != 2 {
+		t.Errorf("duration series = %d, want 2", got)
+	}
+}
+
+func TestParallel(t *testing.T) {
+	err := Parallel([]string{"a", "b", "c"}, func(name string) error {
+		if name == "b" {
+			return errors.New("timeout")
+		}
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "b: timeout") || strings.Contains(err.Error(), "a:") {
+		t.Errorf("Parallel() error = %v, want the error of b only", err)
+	}
+
+	if err := Parallel(nil, func(string) error { return errors.New("unexpected") }); err != nil {
+		t.Errorf("Parallel() of no names error = %v", err)
+	}
+}
+
+func TestScheduler_Overrun(t *testing.T) {
+	target := Target{Name: "slow", Options: Options{Enabled: true, Interval: 10 * time.Millisecond, Timeout: time.Second}}
+	var scrapes atomic.Int32
+	probe := NewProbe("test", []Target{target}, func(ctx context.Context, target Target) error {
+		scrapes.Add(1)
+		time.Sleep(25 * time.Millisecond)
+		return nil
+	})
+
+	s := NewScheduler(prometheus.NewRegistry(), slog.Default())
+	s.maxJitter = 0
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Millisecond)
+	defer cancel()
+	s.Run(ctx, probe)
+
+	// every scrape overruns two rounds, which are skipped instead of queued
+	skipped := testutil.ToFloat64(s.skipped.WithLabelValues("slow"))
+	if got := float64(scrapes.Load()); skipped < got {
+		t.Errorf("skipped rounds = %v, want at least %v of the scrapes", skipped, got)
+	}
+	if got := scrapes.Load(); got > 3 {
+		t.Errorf("scrapes = %d, want at most 3", got)
+	}
+}
+
+func TestScheduler_ZeroInterval(t *testing.T) {
+	var scrapes atomic.Int32
+	probe := NewProbe("test", []Target{
+		{Name: "zero", Options: Options{Enabled: true, Timeout: time.Second}},
+	}, func(ctx context.Context, target Target) error {
+		scrapes.Add(1)
+		return nil
+	})
+
+	s := NewScheduler(prometheus.NewRegistry(), slog.Default())
+	s.maxJitter = 0
+
+	// the loop of the zero interval returns rather than spinning or dividing by zero
+	done := make(chan struct{})
+	go func() {
+		s.Run(context.Background(), probe)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Scheduler.Run() didn't return for the zero interval")
+	}
+	if got := scrapes.Load(); got != 0 {
+		t.Errorf("scrapes = %d, want 0", got)
+	}
+}
+
+func TestScheduler_Singleflight(t *testing.T) {
+	target := Target{Name: "slow", Options: Options{Enabled: true, Interval: time.Minute, Timeout: time.Second}}
+	var scrapes atomic.Int32
+	probe := NewProbe("test", []Target{target}, func(ctx context.Context, target Target) error {
+		scrapes.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return errors.New("timeout")
+	})
+
+	s := NewScheduler(prometheus.NewRegistry(), slog.Default())
+
+	errs := make(chan error, 5)
+	for i := 0; i < cap(errs); i++ {
+		go func() { errs <- s.Scrape(context.Background(), probe, target) }()
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err == nil {
+			t.Errorf("Scrape() error = nil, want the shared error")
+		}
+	}
+
+	if got := scrapes.Load(); got != 1 {
+		t.Errorf("scrapes = %d, want 1 shared by the concurrent calls", got)
+	}
+	if got := testutil.ToFloat64(s.failures.WithLabelValues("slow")); got != 1 {
+		t.Errorf("failures = %v, want 1", got)
+	}
+}
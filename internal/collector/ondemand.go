@@ -0,0 +1,148 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// OnDemand scrapes the targets when it's gathered instead of polling them in the background,
+// so the scrape interval of prometheus sets the freshness of the metrics.
+// It's the registerer of the metrics which are updated by the probes, they're checked and kept
+// by its own registry, and they're gathered after the targets are scraped.
+// The metrics requests should go through Handler, so the scrapes are bounded by the requests.
+// The other gatherers of the exporter, e.g. the remote write sender, should use Cached instead,
+// so they don't scrape the targets at their own intervals.
+type OnDemand struct {
+	basectx     context.Context
+	minAge      time.Duration
+	concurrency int
+	registry    *prometheus.Registry
+
+	mutex sync.Mutex
+	jobs  []job
+	// scrapedAt is the last observed time of the targets, the targets which are scraped
+	// within the min age are not scraped again by the overlapping gathers
+	scrapedAt map[jobKey]time.Time
+}
+
+type job struct {
+	scheduler *Scheduler
+	probe     Probe
+	target    Target
+}
+
+type jobKey struct {
+	scheduler *Scheduler
+	target    string
+}
+
+// NewOnDemand returns the on-demand gatherer, the scrape results are reused within the min age,
+// and at most the concurrency targets are scraped at the same time
+func NewOnDemand(basectx context.Context, minAge time.Duration, concurrency int) *OnDemand {
+	return &OnDemand{
+		basectx:     basectx,
+		minAge:      minAge,
+		concurrency: max(concurrency, 1),
+		registry:    prometheus.NewRegistry(),
+		scrapedAt:   make(map[jobKey]time.Time),
+	}
+}
+
+// Add adds the enabled targets of the probes, the results are recorded by the scheduler
+func (o *OnDemand) Add(s *Scheduler, probes ...Probe) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	s.eachTarget(probes, func(p Probe, target Target) {
+		o.jobs = append(o.jobs, job{scheduler: s, probe: p, target: target})
+	})
+}
+
+// Register implements the prometheus.Registerer, the collectors are checked by the registry
+// of the OnDemand in the same way as the one of the exporter
+func (o *OnDemand) Register(c prometheus.Collector) error {
+	return o.registry.Register(c)
+}
+
+func (o *OnDemand) MustRegister(cs ...prometheus.Collector) {
+	o.registry.MustRegister(cs...)
+}
+
+func (o *OnDemand) Unregister(c prometheus.Collector) bool {
+	return o.registry.Unregister(c)
+}
+
+// Gather scrapes the targets which are older than the min age and then gathers the metrics
+func (o *OnDemand) Gather() ([]*dto.MetricFamily, error) {
+	o.refresh(o.basectx)
+	return o.registry.Gather()
+}
+
+// Handler scrapes the targets which are older than the min age before serving the metrics request,
+// the scrapes are cancelled with the request, so the next handler should gather the Cached metrics
+func (o *OnDemand) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		o.refresh(r.Context())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Cached returns the gatherer of the metrics as of the last scrapes, it never scrapes the targets
+func (o *OnDemand) Cached() prometheus.Gatherer {
+	return o.registry
+}
+
+// refresh scrapes the stale targets concurrently and waits for them, the scrapes are cancelled
+// when either the context or the base context is done, and the overlapping scrapes of the same target
+// are merged by the scheduler
+func (o *OnDemand) refresh(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(o.basectx, cancel)
+	defer stop()
+
+	now := time.Now()
+
+	o.mutex.Lock()
+	var stale []job
+	for _, j := range o.jobs {
+		key := jobKey{scheduler: j.scheduler, target: j.target.Name}
+		if at, ok := o.scrapedAt[key]; ok && now.Sub(at) < o.minAge {
+			continue
+		}
+		stale = append(stale, j)
+	}
+	o.mutex.Unlock()
+
+	sem := make(chan struct{}, o.concurrency)
+	var wg sync.WaitGroup
+	for _, j := range stale {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			// the failed scrapes are cached as well, so a down target isn't hammered by every gather,
+			// but the cancelled ones are scraped again by the next one
+			_ = j.scheduler.Scrape(ctx, j.probe, j.target)
+			if ctx.Err() != nil {
+				return
+			}
+
+			o.mutex.Lock()
+			o.scrapedAt[jobKey{scheduler: j.scheduler, target: j.target.Name}] = time.Now()
+			o.mutex.Unlock()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestOnDemand_Collect(t *testing.T) {
+	tests := []struct {
+		name        string
+		minAge      time.Duration
+		wantScrapes int32
+	}{
+		{
+			name:        "cached",
+			minAge:      time.Minute,
+			wantScrapes: 2,
+		},
+		{
+			name:        "no-cache",
+			minAge:      0,
+			wantScrapes: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewOnDemand(context.Background(), tt.minAge, 1)
+			netreg := prometheus.WrapRegistererWith(prometheus.Labels{"network": "mainnet"}, o)
+
+			height := prometheus.NewGauge(prometheus.GaugeOpts{Name: "height", Help: "height"})
+			netreg.MustRegister(height)
+
+			var scrapes atomic.Int32
+			opts := Options{Enabled: true, Interval: time.Minute, Timeout: time.Second}
+			probe := NewProbe("test", []Target{
+				{Name: "a", Options: opts},
+				{Name: "b", Options: opts},
+				{Name: "disabled"},
+			}, func(ctx context.Context, target Target) error {
+				height.Set(float64(scrapes.Add(1)))
+				return nil
+			})
+			o.Add(NewScheduler(netreg, slog.Default()), probe)
+
+			// the targets are scraped before the metrics are gathered
+			for i := 0; i < 2; i++ {
+				if _, err := o.Gather(); err != nil {
+					t.Fatalf("Gather() error = %v", err)
+				}
+			}
+			// the cached metrics don't scrape the targets
+			if _, err := o.Cached().Gather(); err != nil {
+				t.Fatalf("Cached().Gather() error = %v", err)
+			}
+			if got := testutil.ToFloat64(height); got != float64(tt.wantScrapes) {
+				t.Errorf("height = %v, want %v", got, tt.wantScrapes)
+			}
+			if got := scrapes.Load(); got != tt.wantScrapes {
+				t.Errorf("scrapes = %d, want %d", got, tt.wantScrapes)
+			}
+
+			count, err := testutil.GatherAndCount(o.Cached(), "height", "metis_sequencer_exporter_up")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 3 {
+				t.Errorf("GatherAndCount() = %d, want 3", count)
+			}
+		})
+	}
+}
+
+func TestOnDemand_Register(t *testing.T) {
+	o := NewOnDemand(context.Background(), time.Minute, 1)
+
+	height := prometheus.NewGauge(prometheus.GaugeOpts{Name: "height", Help: "height"})
+	if err := o.Register(height); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	// the duplicate is rejected at the registration like the registry of the exporter
+	duplicate := prometheus.NewGauge(prometheus.GaugeOpts{Name: "height", Help: "height"})
+	if err := o.Register(duplicate); err == nil {
+		t.Errorf("Register() of the duplicate error = nil")
+	}
+	inconsistent := prometheus.NewCounter(prometheus.CounterOpts{Name: "height", Help: "another help"})
+	if err := o.Register(inconsistent); err == nil {
+		t.Errorf("Register() of the inconsistent error = nil")
+	}
+}
+
+func TestOnDemand_Handler(t *testing.T) {
+	o := NewOnDemand(context.Background(), time.Minute, 1)
+
+	var scrapes atomic.Int32
+	started := make(chan struct{}, 1)
+	probe := NewProbe("test", []Target{
+		{Name: "slow", Options: Options{Enabled: true, Interval: time.Minute, Timeout: time.Minute}},
+	}, func(ctx context.Context, target Target) error {
+		scrapes.Add(1)
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	o.Add(NewScheduler(o, slog.Default()), probe)
+
+	var served atomic.Bool
+	handler := o.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served.Store(true)
+	}))
+
+	// the cancelled request cancels the scrape instead of waiting for the timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil).WithContext(ctx))
+		close(done)
+	}()
+	<-started
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the scrape isn't cancelled with the request")
+	}
+	if !served.Load() {
+		t.Errorf("the next handler isn't called")
+	}
+
+	// the cancelled scrape isn't cached, so the next request scrapes the target again
+	next, cancelNext := context.WithCancel(context.Background())
+	defer cancelNext()
+	go func() {
+		<-started
+		cancelNext()
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil).WithContext(next))
+	if got := scrapes.Load(); got != 2 {
+		t.Errorf("scrapes = %d, want 2", got)
+	}
+}
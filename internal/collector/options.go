@@ -0,0 +1,41 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+)
+
+// MaxTimeout is the upper bound of the default scrape timeout
+const MaxTimeout = time.Minute
+
+// Options is the resolved schedule of a target
+type Options struct {
+	Enabled  bool
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// NewOptions resolves the schedule from the default interval and the config,
+// the latter config overrides the former one and the timeout defaults to the interval
+func NewOptions(interval time.Duration, confs ...*config.Scrape) Options {
+	opts := Options{Enabled: true, Interval: interval}
+	for _, conf := range confs {
+		if conf == nil {
+			continue
+		}
+		if conf.Enabled != nil {
+			opts.Enabled = *conf.Enabled
+		}
+		if conf.Interval != nil && *conf.Interval > 0 {
+			opts.Interval = time.Duration(*conf.Interval)
+		}
+		if conf.Timeout > 0 {
+			opts.Timeout = time.Duration(conf.Timeout)
+		}
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = min(opts.Interval, MaxTimeout)
+	}
+	return opts
+}
@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+)
+
+func TestNewOptions(t *testing.T) {
+	disabled := false
+	duration := func(d time.Duration) *config.Duration {
+		v := config.Duration(d)
+		return &v
+	}
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		confs    []*config.Scrape
+		want     Options
+	}{
+		{
+			name:     "default",
+			interval: 15 * time.Second,
+			want:     Options{Enabled: true, Interval: 15 * time.Second, Timeout: 15 * time.Second},
+		},
+		{
+			name:     "default-timeout-capped",
+			interval: 5 * time.Minute,
+			confs:    []*config.Scrape{nil},
+			want:     Options{Enabled: true, Interval: 5 * time.Minute, Timeout: time.Minute},
+		},
+		{
+			// the zero interval is rejected by the config validation, it never replaces the default
+			name:     "zero-interval",
+			interval: 15 * time.Second,
+			confs:    []*config.Scrape{{Interval: duration(0)}},
+			want:     Options{Enabled: true, Interval: 15 * time.Second, Timeout: 15 * time.Second},
+		},
+		{
+			name:     "network-default",
+			interval: 15 * time.Second,
+			confs:    []*config.Scrape{{Interval: duration(time.Minute)}, nil},
+			want:     Options{Enabled: true, Interval: time.Minute, Timeout: time.Minute},
+		},
+		{
+			name:     "override",
+			interval: 15 * time.Second,
+			confs: []*config.Scrape{
+				{Interval: duration(time.Minute), Timeout: config.Duration(30 * time.Second)},
+				{Interval: duration(5 * time.Second), Enabled: &disabled},
+			},
+			want: Options{Enabled: false, Interval: 5 * time.Second, Timeout: 30 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewOptions(tt.interval, tt.confs...); got != tt.want {
+				t.Errorf("NewOptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
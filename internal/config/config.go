@@ -1,8 +1,12 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path"
 
@@ -10,23 +14,297 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// Scrape is the schedule of a scraped service, the unset values fall back to the defaults
+type Scrape struct {
+	Enabled  *bool     `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	Interval *Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+	Timeout  Duration  `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// the scraped services and the endpoints of the sequencers and the wallet
+var (
+	SequencerServices = []string{"l2geth", "themis", "l1dtl", "stateroot"}
+	WalletServices    = []string{"l2geth", "l1geth", "txs", "rollup"}
+
+	SequencerEndpoints = []string{"l2geth", "themis", "l1dtl"}
+	WalletEndpoints    = []string{"l2geth", "l1geth", "themis"}
+)
+
+// LogModules are the modules whose log levels can be set in the config
+var LogModules = []string{"sequencer", "wallet", "collector", "probe", "otlp", "remote_write", "alert", "ethrpc"}
+
+// SequencerScrape is the schedule of every service of a sequencer
+type SequencerScrape struct {
+	L2Geth    *Scrape `json:"l2geth,omitempty" yaml:"l2geth,omitempty"`
+	Themis    *Scrape `json:"themis,omitempty" yaml:"themis,omitempty"`
+	L1DTL     *Scrape `json:"l1dtl,omitempty" yaml:"l1dtl,omitempty"`
+	StateRoot *Scrape `json:"stateroot,omitempty" yaml:"stateroot,omitempty"`
+}
+
+// Service returns the schedule of the service, it's safe to call on nil
+func (s *SequencerScrape) Service(name string) *Scrape {
+	if s == nil {
+		return nil
+	}
+	switch name {
+	case "l2geth":
+		return s.L2Geth
+	case "themis":
+		return s.Themis
+	case "l1dtl":
+		return s.L1DTL
+	case "stateroot":
+		return s.StateRoot
+	}
+	return nil
+}
+
+// WalletScrape is the schedule of every scrape loop of the wallet
+type WalletScrape struct {
+	L2Geth *Scrape `json:"l2geth,omitempty" yaml:"l2geth,omitempty"`
+	L1Geth *Scrape `json:"l1geth,omitempty" yaml:"l1geth,omitempty"`
+	Txs    *Scrape `json:"txs,omitempty" yaml:"txs,omitempty"`
+	Rollup *Scrape `json:"rollup,omitempty" yaml:"rollup,omitempty"`
+}
+
+// Service returns the schedule of the service, it's safe to call on nil
+func (s *WalletScrape) Service(name string) *Scrape {
+	if s == nil {
+		return nil
+	}
+	switch name {
+	case "l2geth":
+		return s.L2Geth
+	case "l1geth":
+		return s.L1Geth
+	case "txs":
+		return s.Txs
+	case "rollup":
+		return s.Rollup
+	}
+	return nil
+}
+
+// TLS is the tls settings of an endpoint, the files are PEM encoded
+type TLS struct {
+	CAFile             string `json:"ca_file,omitempty" yaml:"ca_file,omitempty"`
+	CertFile           string `json:"cert_file,omitempty" yaml:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty" yaml:"key_file,omitempty"`
+	ServerName         string `json:"server_name,omitempty" yaml:"server_name,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+}
+
+// HTTPClient is the tls and auth settings of an endpoint, it's used by both rest and json-rpc clients
+type HTTPClient struct {
+	TLS         *TLS              `json:"tls,omitempty" yaml:"tls,omitempty"`
+	BasicAuth   *BasicAuth        `json:"basic_auth,omitempty" yaml:"basic_auth,omitempty"`
+	BearerToken string            `json:"bearer_token,omitempty" yaml:"bearer_token,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+
+	BearerTokenFile string `json:"bearer_token_file,omitempty" yaml:"bearer_token_file,omitempty"`
+}
+
+// SequencerClients is the client settings of every service of a sequencer
+type SequencerClients struct {
+	L2Geth *HTTPClient `json:"l2geth,omitempty" yaml:"l2geth,omitempty"`
+	Themis *HTTPClient `json:"themis,omitempty" yaml:"themis,omitempty"`
+	L1DTL  *HTTPClient `json:"l1dtl,omitempty" yaml:"l1dtl,omitempty"`
+}
+
+// Service returns the client settings of the service, it's safe to call on nil
+func (c *SequencerClients) Service(name string) *HTTPClient {
+	if c == nil {
+		return nil
+	}
+	switch name {
+	case "l2geth":
+		return c.L2Geth
+	case "themis":
+		return c.Themis
+	case "l1dtl":
+		return c.L1DTL
+	}
+	return nil
+}
+
+// WalletClients is the client settings of every endpoint of the wallet
+type WalletClients struct {
+	L2Geth *HTTPClient `json:"l2geth,omitempty" yaml:"l2geth,omitempty"`
+	L1Geth *HTTPClient `json:"l1geth,omitempty" yaml:"l1geth,omitempty"`
+	Themis *HTTPClient `json:"themis,omitempty" yaml:"themis,omitempty"`
+}
+
+// Service returns the client settings of the service, it's safe to call on nil
+func (c *WalletClients) Service(name string) *HTTPClient {
+	if c == nil {
+		return nil
+	}
+	switch name {
+	case "l2geth":
+		return c.L2Geth
+	case "l1geth":
+		return c.L1Geth
+	case "themis":
+		return c.Themis
+	}
+	return nil
+}
+
 type Sequencer struct {
-	L1DTL  string `json:"l1dtl,omitempty" yaml:"l1dtl,omitempty"`
-	Themis string `json:"themis,omitempty" yaml:"themis,omitempty"`
-	L2Geth string `json:"l2geth" yaml:"l2geth"`
+	L1DTL   string            `json:"l1dtl,omitempty" yaml:"l1dtl,omitempty"`
+	Themis  string            `json:"themis,omitempty" yaml:"themis,omitempty"`
+	L2Geth  string            `json:"l2geth" yaml:"l2geth"`
+	Scrape  *SequencerScrape  `json:"scrape,omitempty" yaml:"scrape,omitempty"`
+	Clients *SequencerClients `json:"clients,omitempty" yaml:"clients,omitempty"`
+
+	// the files to read the urls from, e.g. the mounted secrets
+	L1DTLFile  string `json:"l1dtl_file,omitempty" yaml:"l1dtl_file,omitempty"`
+	ThemisFile string `json:"themis_file,omitempty" yaml:"themis_file,omitempty"`
+	L2GethFile string `json:"l2geth_file,omitempty" yaml:"l2geth_file,omitempty"`
+}
+
+type Rollup struct {
+	ChainID uint64         `json:"chain_id,omitempty" yaml:"chain_id,omitempty"`
+	CTC     common.Address `json:"ctc" yaml:"ctc"`
+	SCC     common.Address `json:"scc" yaml:"scc"`
 }
 
 type Wallet struct {
 	Themis    string                    `json:"themis,omitempty" yaml:"themis,omitempty"`
-	L2Geth    string                    `json:"l2geth" yaml:"l2geth"`
-	L1Geth    string                    `json:"l1geth" yaml:"l1geth"`
+	L2Geth    URLs                      `json:"l2geth" yaml:"l2geth"`
+	L1Geth    URLs                      `json:"l1geth" yaml:"l1geth"`
 	Wallets   map[string]common.Address `json:"wallets" yaml:"wallets"`
 	L2Wallets map[string]common.Address `json:"l2_wallets" yaml:"l2_wallets"`
+	Rollup    *Rollup                   `json:"rollup,omitempty" yaml:"rollup,omitempty"`
+	Scrape    *WalletScrape             `json:"scrape,omitempty" yaml:"scrape,omitempty"`
+	Clients   *WalletClients            `json:"clients,omitempty" yaml:"clients,omitempty"`
+
+	// the number of l1geth urls to read the eth balances, nonces and the l1 head from,
+	// the reads fail if most of them don't agree, 0 to read from the active url only
+	L1Quorum int `json:"l1_quorum,omitempty" yaml:"l1_quorum,omitempty"`
+
+	// the minimum balances by the wallet alias, the mpc aliases are included
+	MinBalance   map[string]float64 `json:"min_balance,omitempty" yaml:"min_balance,omitempty"`
+	L2MinBalance map[string]float64 `json:"l2_min_balance,omitempty" yaml:"l2_min_balance,omitempty"`
+
+	// the files to read the urls from, e.g. the mounted secrets, one url per line
+	ThemisFile string `json:"themis_file,omitempty" yaml:"themis_file,omitempty"`
+	L2GethFile string `json:"l2geth_file,omitempty" yaml:"l2geth_file,omitempty"`
+	L1GethFile string `json:"l1geth_file,omitempty" yaml:"l1geth_file,omitempty"`
 }
 
-type Config struct {
+// DefaultNetwork is the network name of the top-level sequencer and wallet sections
+const DefaultNetwork = "default"
+
+type Network struct {
 	Sequencers map[string]*Sequencer `json:"sequencer" yaml:"sequencer"`
 	Wallet     *Wallet               `json:"wallet,omitempty" yaml:"wallet,omitempty"`
+
+	// the default schedule of the services of all sequencers
+	Scrape *SequencerScrape `json:"scrape,omitempty" yaml:"scrape,omitempty"`
+}
+
+func (n *Network) IsEmpty() bool {
+	return len(n.Sequencers) == 0 && n.Wallet == nil
+}
+
+type OTLP struct {
+	Endpoint           string            `json:"endpoint" yaml:"endpoint"`
+	EndpointFile       string            `json:"endpoint_file,omitempty" yaml:"endpoint_file,omitempty"`
+	Interval           Duration          `json:"interval,omitempty" yaml:"interval,omitempty"`
+	Headers            map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	ResourceAttributes map[string]string `json:"resource_attributes,omitempty" yaml:"resource_attributes,omitempty"`
+	// Client is the tls and auth settings of the collector
+	Client *HTTPClient `json:"client,omitempty" yaml:"client,omitempty"`
+}
+
+type BasicAuth struct {
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+
+	PasswordFile string `json:"password_file,omitempty" yaml:"password_file,omitempty"`
+}
+
+type RemoteWrite struct {
+	URL         string            `json:"url" yaml:"url"`
+	Interval    Duration          `json:"interval,omitempty" yaml:"interval,omitempty"`
+	Timeout     Duration          `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	BasicAuth   *BasicAuth        `json:"basic_auth,omitempty" yaml:"basic_auth,omitempty"`
+	BearerToken string            `json:"bearer_token,omitempty" yaml:"bearer_token,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	QueueSize   int               `json:"queue_size,omitempty" yaml:"queue_size,omitempty"`
+	MaxRetries  int               `json:"max_retries,omitempty" yaml:"max_retries,omitempty"`
+	// ExternalLabels are attached to every series, the labels of the series take precedence
+	ExternalLabels map[string]string `json:"external_labels,omitempty" yaml:"external_labels,omitempty"`
+	// Client is the tls and auth settings of the receiver, the auth is set either here or in the section
+	Client *HTTPClient `json:"client,omitempty" yaml:"client,omitempty"`
+
+	URLFile         string `json:"url_file,omitempty" yaml:"url_file,omitempty"`
+	BearerTokenFile string `json:"bearer_token_file,omitempty" yaml:"bearer_token_file,omitempty"`
+}
+
+type AlertRule struct {
+	Name      string            `json:"name" yaml:"name"`
+	Type      string            `json:"type" yaml:"type"`
+	Threshold float64           `json:"threshold,omitempty" yaml:"threshold,omitempty"`
+	Window    Duration          `json:"window,omitempty" yaml:"window,omitempty"`
+	For       Duration          `json:"for,omitempty" yaml:"for,omitempty"`
+	Severity  string            `json:"severity,omitempty" yaml:"severity,omitempty"`
+	Matchers  map[string]string `json:"matchers,omitempty" yaml:"matchers,omitempty"`
+}
+
+type AlertReceiver struct {
+	Name     string            `json:"name" yaml:"name"`
+	URL      string            `json:"url" yaml:"url"`
+	URLFile  string            `json:"url_file,omitempty" yaml:"url_file,omitempty"`
+	Format   string            `json:"format,omitempty" yaml:"format,omitempty"`
+	Template string            `json:"template,omitempty" yaml:"template,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	// Client is the tls and auth settings of the webhook
+	Client *HTTPClient `json:"client,omitempty" yaml:"client,omitempty"`
+}
+
+type Alerting struct {
+	Interval       Duration         `json:"interval,omitempty" yaml:"interval,omitempty"`
+	RepeatInterval Duration         `json:"repeat_interval,omitempty" yaml:"repeat_interval,omitempty"`
+	Rules          []*AlertRule     `json:"rules" yaml:"rules"`
+	Receivers      []*AlertReceiver `json:"receivers" yaml:"receivers"`
+}
+
+type Log struct {
+	// the levels by the module, which override the level of the flag
+	Levels map[string]slog.Level `json:"levels,omitempty" yaml:"levels,omitempty"`
+}
+
+type Config struct {
+	// the top-level sections are the implicit default network
+	Network     `yaml:",inline"`
+	Networks    map[string]*Network `json:"networks,omitempty" yaml:"networks,omitempty"`
+	OTLP        *OTLP               `json:"otlp,omitempty" yaml:"otlp,omitempty"`
+	RemoteWrite *RemoteWrite        `json:"remote_write,omitempty" yaml:"remote_write,omitempty"`
+	Alerting    *Alerting           `json:"alerting,omitempty" yaml:"alerting,omitempty"`
+	Log         *Log                `json:"log,omitempty" yaml:"log,omitempty"`
+}
+
+// decode decodes the json or yaml file into the config, the unknown fields are rejected if strict is set
+func decode(ext string, file []byte, conf *Config, strict bool) error {
+	switch ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(file))
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		return dec.Decode(conf)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(file))
+		dec.KnownFields(strict)
+		if err := dec.Decode(conf); !errors.Is(err, io.EOF) {
+			return err
+		}
+		return nil
+	default:
+		return fmt.Errorf("not supported file extension %s", ext)
+	}
 }
 
 func Parse(p string) (*Config, error) {
@@ -36,16 +314,45 @@ func Parse(p string) (*Config, error) {
 	}
 
 	conf := new(Config)
-	switch ext := path.Ext(p); ext {
-	case ".json":
-		err = json.Unmarshal(file, &conf)
-	case ".yaml", ".yml":
-		err = yaml.Unmarshal(file, &conf)
-	default:
-		err = fmt.Errorf("not supported file extension %s", ext)
+	ext := path.Ext(p)
+	if err := decode(ext, file, conf, false); err != nil {
+		return nil, err
 	}
-	if err != nil {
+
+	// the unknown fields are reported with the other problems of the config,
+	// so a typo doesn't hide them, json only reports the first one
+	var unknown []string
+	if err := decode(ext, file, new(Config), true); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			unknown = typeErr.Errors
+		} else {
+			unknown = []string{err.Error()}
+		}
+	}
+
+	if err := expandEnv(conf); err != nil {
+		return nil, err
+	}
+
+	if err := conf.readSecretFiles(); err != nil {
 		return nil, err
 	}
+
+	if err := conf.Validate(); err != nil || len(unknown) > 0 {
+		verr := new(ValidationError)
+		if err != nil && !errors.As(err, &verr) {
+			return nil, err
+		}
+		verr.Problems = append(unknown, verr.Problems...)
+		return nil, verr
+	}
+
+	if !conf.Network.IsEmpty() {
+		if conf.Networks == nil {
+			conf.Networks = make(map[string]*Network)
+		}
+		conf.Networks[DefaultNetwork] = &conf.Network
+	}
 	return conf, nil
 }
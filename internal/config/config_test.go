@@ -0,0 +1,460 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse_Networks(t *testing.T) {
+	tests := []struct {
+		name         string
+		file         string
+		content      string
+		wantNetworks []string
+		wantErr      bool
+	}{
+		{
+			name: "implicit-default",
+			file: "config.yaml",
+			content: `
+sequencer:
+  node-0:
+    l2geth: http://localhost:8545
+`,
+			wantNetworks: []string{DefaultNetwork},
+		},
+		{
+			name: "networks",
+			file: "config.yaml",
+			content: `
+networks:
+  mainnet:
+    sequencer:
+      node-0:
+        l2geth: http://localhost:8545
+  sepolia:
+    sequencer:
+      node-0:
+        l2geth: http://localhost:18545
+`,
+			wantNetworks: []string{"mainnet", "sepolia"},
+		},
+		{
+			name: "networks-json",
+			file: "config.json",
+			content: `{
+  "sequencer": {"node-0": {"l2geth": "http://localhost:8545"}},
+  "networks": {"sepolia": {"sequencer": {"node-0": {"l2geth": "http://localhost:18545"}}}}
+}`,
+			wantNetworks: []string{DefaultNetwork, "sepolia"},
+		},
+		{
+			name: "duplicated-default",
+			file: "config.yaml",
+			content: `
+sequencer:
+  node-0:
+    l2geth: http://localhost:8545
+networks:
+  default:
+    sequencer:
+      node-0:
+        l2geth: http://localhost:18545
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(p, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Parse(p)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			var networks []string
+			for name, network := range got.Networks {
+				networks = append(networks, name)
+				if len(network.Sequencers) == 0 {
+					t.Errorf("Parse() network %s has no sequencers", name)
+				}
+			}
+			slices.Sort(networks)
+			if !reflect.DeepEqual(networks, tt.wantNetworks) {
+				t.Errorf("Parse() networks = %v, want %v", networks, tt.wantNetworks)
+			}
+		})
+	}
+}
+
+func TestParse_Validate(t *testing.T) {
+	tests := []struct {
+		name         string
+		file         string
+		content      string
+		wantProblems []string
+		wantErr      string
+	}{
+		{
+			name: "ok",
+			file: "config.yaml",
+			content: `
+sequencer:
+  node-0:
+    l2geth: ws://localhost:8546
+    themis: http://localhost:1317
+wallet:
+  l1geth:
+    - https://localhost:8545
+    - https://backup:8545
+  l1_quorum: 2
+  l2geth: http://localhost:8545
+  themis: http://localhost:1317
+  wallets:
+    custom: "0x0000000000000000000000000000000000000001"
+  min_balance:
+    CommonMpcAddr: 3
+    custom: 1
+log:
+  levels:
+    wallet: debug
+    sequencer: WARN
+`,
+		},
+		{
+			name: "scrape-clients",
+			file: "config.yaml",
+			content: `
+scrape:
+  themis:
+    interval: 1m
+sequencer:
+  node-0:
+    l2geth: http://localhost:8545
+    clients:
+      l2geth:
+        bearer_token: token
+        basic_auth:
+          username: user
+      themis:
+        tls:
+          cert_file: client.pem
+    scrape:
+      l2geth:
+        interval: 5s
+        timeout: 10s
+      l1dtl:
+        enabled: false
+        timeout: -1s
+wallet:
+  l1geth: http://localhost:8545
+  l2geth: http://localhost:8545
+  scrape:
+    txs:
+      interval: -1m
+    rollup:
+      interval: 0s
+`,
+			wantProblems: []string{
+				"sequencer.node-0.scrape.l2geth.timeout: timeout 10s is longer than the interval 5s",
+				"sequencer.node-0.scrape.l1dtl.timeout: negative duration",
+				"sequencer.node-0.clients.l2geth: basic_auth and bearer_token are exclusive",
+				"sequencer.node-0.clients.themis.tls: cert_file and key_file must be set together",
+				"wallet.scrape.txs.interval: interval -1m0s is not positive",
+				"wallet.scrape.rollup.interval: interval 0s is not positive",
+			},
+		},
+		{
+			name:    "json-urls",
+			file:    "config.json",
+			content: `{"wallet": {"l1geth": ["http://localhost:8545", "http://backup:8545"], "l2geth": "http://localhost:8545"}}`,
+		},
+		{
+			name: "unknown-yaml-field",
+			file: "config.yaml",
+			content: `
+sequencer:
+  node-0:
+    l2geth: http://localhost:8545
+    l2gteh: http://localhost:8545
+`,
+			wantErr: "field l2gteh not found",
+		},
+		{
+			name:    "unknown-json-field",
+			file:    "config.json",
+			content: `{"sequencer": {"node-0": {"l2geth": "http://localhost:8545", "l2gteh": ""}}}`,
+			wantErr: `unknown field "l2gteh"`,
+		},
+		{
+			name: "unknown-field-problems",
+			file: "config.yaml",
+			content: `
+sequencer:
+  node-0:
+    l2geth: localhost:8545
+    l2gteh: http://localhost:8545
+networks:
+  sepolia:
+    wallet:
+      l1geth: http://localhost:8545
+      l2geth: http://localhost:8546
+      wallets:
+        BlobSubmitMpcAddr: "0x0000000000000000000000000000000000000001"
+`,
+			wantProblems: []string{
+				"line 5: field l2gteh not found in type config.Sequencer",
+				`sequencer.node-0.l2geth: unsupported url scheme "localhost", expected one of http, https, ws, wss`,
+				"networks.sepolia.wallet.wallets.BlobSubmitMpcAddr: alias is reserved for the mpc address",
+			},
+		},
+		{
+			name:    "unknown-json-field-problems",
+			file:    "config.json",
+			content: `{"sequencer": {"node-0": {"l2geth": "", "l2gteh": ""}}}`,
+			wantProblems: []string{
+				`json: unknown field "l2gteh"`,
+				"sequencer.node-0.l2geth: url is required",
+			},
+		},
+		{
+			name: "problems",
+			file: "config.yaml",
+			content: `
+sequencer:
+  node-0:
+    l2geth: ""
+  node-1:
+    l2geth: localhost:8545
+    l1dtl: ws://localhost:7878
+networks:
+  sepolia:
+    wallet:
+      l1geth: http://localhost:8545
+      l2geth: [http://localhost:8545, localhost:8546, http://localhost:8545]
+      l1_quorum: 3
+      themis: http://localhost:1317
+      wallets:
+        CommonMpcAddr: "0x0000000000000000000000000000000000000001"
+        zero: "0x0000000000000000000000000000000000000000"
+      l2_wallets:
+        zero: "0x0000000000000000000000000000000000000002"
+      min_balance:
+        unknown: 1
+remote_write:
+  url: http://localhost:9090/api/v1/write
+  external_labels:
+    cluster: andromeda
+    1cluster: andromeda
+    __name__: test
+    region: ""
+  bearer_token: secret
+  client:
+    bearer_token: secret
+otlp:
+  endpoint: http://localhost:4318/v1/metrics
+  client:
+    tls:
+      cert_file: client.pem
+alerting:
+  receivers:
+    - name: ops
+      url: http://localhost:8080/hook
+      client:
+        headers:
+          " ": value
+log:
+  levels:
+    wallets: debug
+`,
+			wantProblems: []string{
+				"sequencer.node-0.l2geth: url is required",
+				`sequencer.node-1.l2geth: unsupported url scheme "localhost", expected one of http, https, ws, wss`,
+				`sequencer.node-1.l1dtl: unsupported url scheme "ws", expected one of http, https`,
+				`networks.sepolia.wallet.l2geth[1]: unsupported url scheme "localhost", expected one of http, https, ws, wss`,
+				"networks.sepolia.wallet.l2geth[2]: url is duplicated",
+				"networks.sepolia.wallet.l1_quorum: quorum 3 is more than the 1 l1geth urls",
+				"networks.sepolia.wallet.wallets.CommonMpcAddr: alias is reserved for the mpc address",
+				"networks.sepolia.wallet.wallets.zero: zero address",
+				"networks.sepolia.wallet.l2_wallets.zero: alias is duplicated with networks.sepolia.wallet.wallets.zero",
+				"networks.sepolia.wallet.min_balance.unknown: unknown wallet alias",
+				"otlp.client.tls: cert_file and key_file must be set together",
+				"remote_write.external_labels.1cluster: invalid label name",
+				"remote_write.external_labels.__name__: label name is reserved",
+				"remote_write.external_labels.region: label value is empty",
+				"remote_write.client: auth is already set in remote_write",
+				"alerting.receivers[0].client.headers: empty header name",
+				"log.levels.wallets: unknown module, expected one of sequencer, wallet, collector, probe, otlp, remote_write, alert, ethrpc",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(p, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := Parse(p)
+			switch {
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Parse() error = %v, want %q", err, tt.wantErr)
+				}
+			case tt.wantProblems != nil:
+				var verr *ValidationError
+				if !errors.As(err, &verr) {
+					t.Fatalf("Parse() error = %v, want ValidationError", err)
+				}
+				if !reflect.DeepEqual(verr.Problems, tt.wantProblems) {
+					t.Errorf("Parse() problems = %q, want %q", verr.Problems, tt.wantProblems)
+				}
+			case err != nil:
+				t.Errorf("Parse() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestParse_Secrets(t *testing.T) {
+	t.Setenv("TEST_L1_KEY", "l1-secret")
+	t.Setenv("TEST_L2_URL", "http://localhost:8545")
+	t.Setenv("TEST_SPECIAL", "a'b\": #c\nd: e")
+
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "l1dtl")
+	if err := os.WriteFile(secret, []byte("http://localhost:7878\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		content    string
+		wantL2Geth string
+		wantL1DTL  string
+		wantThemis string
+		wantErr    string
+	}{
+		{
+			name: "env",
+			content: `
+sequencer:
+  node-0:
+    l2geth: ${TEST_L2_URL}
+    themis: http://localhost:1317/${TEST_L1_KEY}
+`,
+			wantL2Geth: "http://localhost:8545",
+			wantThemis: "http://localhost:1317/l1-secret",
+		},
+		{
+			name: "escaped",
+			content: `
+sequencer:
+  node-0:
+    l2geth: ${TEST_L2_URL}
+    themis: http://localhost:1317/$${TEST_L1_KEY}
+`,
+			wantL2Geth: "http://localhost:8545",
+			wantThemis: "http://localhost:1317/${TEST_L1_KEY}",
+		},
+		{
+			name: "comment",
+			content: `
+sequencer:
+  # l2geth: ${TEST_NOT_SET_URL}
+  node-0:
+    l2geth: ${TEST_L2_URL}
+`,
+			wantL2Geth: "http://localhost:8545",
+		},
+		{
+			// the value is not parsed as yaml
+			name: "special",
+			content: `
+sequencer:
+  node-0:
+    l2geth: ${TEST_L2_URL}
+    themis: http://localhost:1317/${TEST_SPECIAL}
+`,
+			wantL2Geth: "http://localhost:8545",
+			wantThemis: "http://localhost:1317/a'b\": #c\nd: e",
+		},
+		{
+			name: "missing-env",
+			content: `
+sequencer:
+  node-0:
+    l2geth: ${TEST_NOT_SET_URL}
+`,
+			wantErr: "TEST_NOT_SET_URL",
+		},
+		{
+			name: "file",
+			content: `
+sequencer:
+  node-0:
+    l2geth: ${TEST_L2_URL}
+    l1dtl_file: ` + secret + `
+`,
+			wantL2Geth: "http://localhost:8545",
+			wantL1DTL:  "http://localhost:7878",
+		},
+		{
+			name: "file-and-value",
+			content: `
+sequencer:
+  node-0:
+    l2geth: ${TEST_L2_URL}
+    l1dtl: http://localhost:7878
+    l1dtl_file: ` + secret + `
+`,
+			wantErr: "sequencer.node-0.l1dtl",
+		},
+		{
+			name: "file-not-found",
+			content: `
+sequencer:
+  node-0:
+    l2geth_file: ` + filepath.Join(dir, "not-found") + `
+`,
+			wantErr: "sequencer.node-0.l2geth_file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(p, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			conf, err := Parse(p)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			seq := conf.Networks[DefaultNetwork].Sequencers["node-0"]
+			if seq.L2Geth != tt.wantL2Geth || seq.L1DTL != tt.wantL1DTL || seq.Themis != tt.wantThemis {
+				t.Errorf("Parse() sequencer = %+v", seq)
+			}
+		})
+	}
+}
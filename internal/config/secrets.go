@@ -0,0 +1,220 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/metis-devops/metis-sequencer-exporter/internal/utils"
+)
+
+// envPattern matches ${VAR} and the escaped form $${VAR}
+var envPattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces the ${VAR} references in the string fields of the decoded config with the
+// environment variables, $${VAR} is kept as the literal ${VAR}. It runs after decoding, so the references
+// in the comments are ignored, and the values are never parsed as yaml or json.
+func expandEnv(conf any) error {
+	var missing []string
+	expandValue(reflect.ValueOf(conf), &missing)
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return fmt.Errorf("environment variables not set: %s", strings.Join(slices.Compact(missing), ", "))
+	}
+	return nil
+}
+
+func expandString(value string, missing *[]string) string {
+	return envPattern.ReplaceAllStringFunc(value, func(m string) string {
+		if m[1] == '$' {
+			return m[1:]
+		}
+		name := m[2 : len(m)-1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			*missing = append(*missing, name)
+		}
+		return value
+	})
+}
+
+// expandValue expands the strings in the value, the map keys are kept as they are
+func expandValue(v reflect.Value, missing *[]string) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			expandValue(v.Elem(), missing)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				expandValue(v.Field(i), missing)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			expandValue(v.Index(i), missing)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if value := iter.Value(); value.Kind() == reflect.String {
+				expanded := reflect.ValueOf(expandString(value.String(), missing)).Convert(value.Type())
+				v.SetMapIndex(iter.Key(), expanded)
+			} else {
+				expandValue(value, missing)
+			}
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(expandString(v.String(), missing))
+		}
+	}
+}
+
+// readSecret sets the value from the file content if the file is provided
+func readSecret(path string, value *string, file string) error {
+	if file == "" {
+		return nil
+	}
+	if *value != "" {
+		return fmt.Errorf("%s: only one of the value and the file can be set", path)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("%s_file: %s", path, err)
+	}
+	*value = strings.TrimSpace(string(data))
+	return nil
+}
+
+// readSecretURLs sets the urls from the file content if the file is provided, one url per line
+func readSecretURLs(path string, value *URLs, file string) error {
+	var content string
+	if len(*value) > 0 && file != "" {
+		return fmt.Errorf("%s: only one of the value and the file can be set", path)
+	}
+	if err := readSecret(path, &content, file); err != nil {
+		return err
+	}
+	if urls := strings.Fields(content); len(urls) > 0 {
+		*value = urls
+	}
+	return nil
+}
+
+func (c *HTTPClient) readSecretFiles(prefix string) error {
+	if c == nil {
+		return nil
+	}
+	if err := readSecret(prefix+"bearer_token", &c.BearerToken, c.BearerTokenFile); err != nil {
+		return err
+	}
+	if ba := c.BasicAuth; ba != nil {
+		if err := readSecret(prefix+"basic_auth.password", &ba.Password, ba.PasswordFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *Network) readSecretFiles(prefix string) error {
+	for _, name := range utils.SortedKeys(n.Sequencers) {
+		seq := n.Sequencers[name]
+		if seq == nil {
+			continue
+		}
+		path := fmt.Sprintf("%ssequencer.%s.", prefix, name)
+		if err := readSecret(path+"l2geth", &seq.L2Geth, seq.L2GethFile); err != nil {
+			return err
+		}
+		if err := readSecret(path+"l1dtl", &seq.L1DTL, seq.L1DTLFile); err != nil {
+			return err
+		}
+		if err := readSecret(path+"themis", &seq.Themis, seq.ThemisFile); err != nil {
+			return err
+		}
+		for _, svc := range SequencerEndpoints {
+			if err := seq.Clients.Service(svc).readSecretFiles(path + "clients." + svc + "."); err != nil {
+				return err
+			}
+		}
+	}
+
+	if w := n.Wallet; w != nil {
+		path := prefix + "wallet."
+		if err := readSecretURLs(path+"l2geth", &w.L2Geth, w.L2GethFile); err != nil {
+			return err
+		}
+		if err := readSecretURLs(path+"l1geth", &w.L1Geth, w.L1GethFile); err != nil {
+			return err
+		}
+		if err := readSecret(path+"themis", &w.Themis, w.ThemisFile); err != nil {
+			return err
+		}
+		for _, svc := range WalletEndpoints {
+			if err := w.Clients.Service(svc).readSecretFiles(path + "clients." + svc + "."); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readSecretFiles resolves the *_file fields into their values
+func (c *Config) readSecretFiles() error {
+	if err := c.Network.readSecretFiles(""); err != nil {
+		return err
+	}
+	for _, name := range utils.SortedKeys(c.Networks) {
+		if n := c.Networks[name]; n != nil {
+			if err := n.readSecretFiles(fmt.Sprintf("networks.%s.", name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if o := c.OTLP; o != nil {
+		if err := readSecret("otlp.endpoint", &o.Endpoint, o.EndpointFile); err != nil {
+			return err
+		}
+		if err := o.Client.readSecretFiles("otlp.client."); err != nil {
+			return err
+		}
+	}
+
+	if rw := c.RemoteWrite; rw != nil {
+		if err := readSecret("remote_write.url", &rw.URL, rw.URLFile); err != nil {
+			return err
+		}
+		if err := readSecret("remote_write.bearer_token", &rw.BearerToken, rw.BearerTokenFile); err != nil {
+			return err
+		}
+		if ba := rw.BasicAuth; ba != nil {
+			if err := readSecret("remote_write.basic_auth.password", &ba.Password, ba.PasswordFile); err != nil {
+				return err
+			}
+		}
+		if err := rw.Client.readSecretFiles("remote_write.client."); err != nil {
+			return err
+		}
+	}
+
+	if a := c.Alerting; a != nil {
+		for i, r := range a.Receivers {
+			if r == nil {
+				continue
+			}
+			if err := readSecret(fmt.Sprintf("alerting.receivers[%d].url", i), &r.URL, r.URLFile); err != nil {
+				return err
+			}
+			if err := r.Client.readSecretFiles(fmt.Sprintf("alerting.receivers[%d].client.", i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// URLs is a list of urls for failover, it's written as a single url or a list in the config file
+type URLs []string
+
+func (u URLs) String() string {
+	return strings.Join(u, ",")
+}
+
+func (u *URLs) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		var value string
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		*u = newURLs(value)
+		return nil
+	}
+
+	var values []string
+	if err := node.Decode(&values); err != nil {
+		return fmt.Errorf("expected a url or a list of urls: %w", err)
+	}
+	*u = values
+	return nil
+}
+
+func (u *URLs) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*u = newURLs(value)
+		return nil
+	}
+
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("expected a url or a list of urls: %w", err)
+	}
+	*u = values
+	return nil
+}
+
+func (u URLs) MarshalYAML() (any, error) {
+	if len(u) == 1 {
+		return u[0], nil
+	}
+	return []string(u), nil
+}
+
+func (u URLs) MarshalJSON() ([]byte, error) {
+	if len(u) == 1 {
+		return json.Marshal(u[0])
+	}
+	return json.Marshal([]string(u))
+}
+
+// newURLs returns nil for the empty url, so it's reported as missing
+func newURLs(value string) URLs {
+	if value == "" {
+		return nil
+	}
+	return URLs{value}
+}
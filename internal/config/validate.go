@@ -0,0 +1,344 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/themis"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/utils"
+)
+
+// the alert rule types
+const (
+	AlertChainStalled   = "chain_stalled"
+	AlertWalletBalance  = "wallet_balance"
+	AlertScrapeFailures = "scrape_failures"
+	AlertSpanEnding     = "span_ending"
+	AlertNonceGap       = "nonce_gap"
+)
+
+// the payload formats of the alert receivers
+const (
+	ReceiverJSON    = "json"
+	ReceiverSlack   = "slack"
+	ReceiverDiscord = "discord"
+)
+
+// the url schemes of the json-rpc and the rest endpoints
+var (
+	RPCSchemes  = []string{"http", "https", "ws", "wss"}
+	RESTSchemes = []string{"http", "https"}
+)
+
+// labelNameRe is the label name syntax of prometheus
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ValidationError contains every problem found in the config
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid config:\n  %s", strings.Join(e.Problems, "\n  "))
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(path, format string, args ...any) {
+	v.problems = append(v.problems, fmt.Sprintf("%s: %s", path, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) url(path, value string, required bool, schemes []string) {
+	if value == "" {
+		if required {
+			v.addf(path, "url is required")
+		}
+		return
+	}
+
+	if err := CheckURL(value, schemes); err != nil {
+		v.addf(path, "%s", err)
+	}
+}
+
+// CheckURL checks the url is absolute and of one of the schemes
+func CheckURL(value string, schemes []string) error {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid url: %s", err)
+	}
+	if !slices.Contains(schemes, parsed.Scheme) {
+		return fmt.Errorf("unsupported url scheme %q, expected one of %s", parsed.Scheme, strings.Join(schemes, ", "))
+	}
+	if parsed.Host == "" {
+		return errors.New("url has no host")
+	}
+	return nil
+}
+
+func (v *validator) scrape(path string, s *Scrape) {
+	if s == nil {
+		return
+	}
+	if s.Interval != nil && *s.Interval <= 0 {
+		v.addf(path+".interval", "interval %s is not positive", s.Interval)
+	}
+	if s.Timeout < 0 {
+		v.addf(path+".timeout", "negative duration")
+	}
+	if s.Interval != nil && *s.Interval > 0 && s.Timeout > *s.Interval {
+		v.addf(path+".timeout", "timeout %s is longer than the interval %s", s.Timeout, s.Interval)
+	}
+}
+
+func (v *validator) client(path string, c *HTTPClient) {
+	if c == nil {
+		return
+	}
+	if c.BasicAuth != nil && c.BearerToken != "" {
+		v.addf(path, "basic_auth and bearer_token are exclusive")
+	}
+	if c.TLS != nil && (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		v.addf(path+".tls", "cert_file and key_file must be set together")
+	}
+	for _, key := range utils.SortedKeys(c.Headers) {
+		if strings.TrimSpace(key) == "" {
+			v.addf(path+".headers", "empty header name")
+		}
+	}
+}
+
+func (v *validator) sequencerScrape(path string, s *SequencerScrape) {
+	for _, svc := range SequencerServices {
+		v.scrape(path+"."+svc, s.Service(svc))
+	}
+}
+
+func (v *validator) urls(path string, values URLs, required bool, schemes []string) {
+	if len(values) <= 1 {
+		v.url(path, strings.Join(values, ""), required, schemes)
+		return
+	}
+
+	seen := make(map[string]bool)
+	for i, value := range values {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		v.url(itemPath, value, true, schemes)
+		if seen[value] {
+			v.addf(itemPath, "url is duplicated")
+		}
+		seen[value] = true
+	}
+}
+
+func (v *validator) address(path string, addr common.Address) {
+	if utils.IsZeroAddress(addr) {
+		v.addf(path, "zero address")
+	}
+}
+
+// Validate checks the config and returns a ValidationError which contains every problem with its path
+func (c *Config) Validate() error {
+	v := new(validator)
+
+	if !c.Network.IsEmpty() {
+		if network, ok := c.Networks[DefaultNetwork]; ok && network != &c.Network {
+			v.addf("networks."+DefaultNetwork, "network is duplicated with the top-level sections")
+		}
+		v.network("", &c.Network)
+	}
+
+	for _, name := range utils.SortedKeys(c.Networks) {
+		network := c.Networks[name]
+		if network == &c.Network {
+			continue
+		}
+		path := "networks." + name
+		if network == nil || network.IsEmpty() {
+			v.addf(path, "network is empty")
+			continue
+		}
+		v.network(path+".", network)
+	}
+
+	if c.OTLP != nil {
+		v.url("otlp.endpoint", c.OTLP.Endpoint, true, RESTSchemes)
+		v.client("otlp.client", c.OTLP.Client)
+	}
+
+	if rw := c.RemoteWrite; rw != nil {
+		v.url("remote_write.url", rw.URL, true, RESTSchemes)
+		if rw.QueueSize < 0 {
+			v.addf("remote_write.queue_size", "negative value %d", rw.QueueSize)
+		}
+		if rw.MaxRetries < 0 {
+			v.addf("remote_write.max_retries", "negative value %d", rw.MaxRetries)
+		}
+		if rw.BasicAuth != nil && rw.BearerToken != "" {
+			v.addf("remote_write", "basic_auth and bearer_token are exclusive")
+		}
+		for _, name := range utils.SortedKeys(rw.ExternalLabels) {
+			path := "remote_write.external_labels." + name
+			switch {
+			case !labelNameRe.MatchString(name):
+				v.addf(path, "invalid label name")
+			case strings.HasPrefix(name, "__"):
+				v.addf(path, "label name is reserved")
+			case rw.ExternalLabels[name] == "":
+				v.addf(path, "label value is empty")
+			}
+		}
+		v.client("remote_write.client", rw.Client)
+		if client := rw.Client; client != nil && (client.BasicAuth != nil || client.BearerToken != "") && (rw.BasicAuth != nil || rw.BearerToken != "") {
+			v.addf("remote_write.client", "auth is already set in remote_write")
+		}
+	}
+
+	if c.Alerting != nil {
+		v.alerting("alerting", c.Alerting)
+	}
+
+	if c.Log != nil {
+		for _, module := range utils.SortedKeys(c.Log.Levels) {
+			if !slices.Contains(LogModules, module) {
+				v.addf("log.levels."+module, "unknown module, expected one of %s", strings.Join(LogModules, ", "))
+			}
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+func (v *validator) network(prefix string, network *Network) {
+	for _, name := range utils.SortedKeys(network.Sequencers) {
+		path := fmt.Sprintf("%ssequencer.%s", prefix, name)
+		seq := network.Sequencers[name]
+		if seq == nil {
+			v.addf(path, "sequencer is empty")
+			continue
+		}
+		v.url(path+".l2geth", seq.L2Geth, true, RPCSchemes)
+		v.url(path+".themis", seq.Themis, false, RESTSchemes)
+		v.url(path+".l1dtl", seq.L1DTL, false, RESTSchemes)
+		v.sequencerScrape(path+".scrape", seq.Scrape)
+		for _, svc := range SequencerEndpoints {
+			v.client(path+".clients."+svc, seq.Clients.Service(svc))
+		}
+	}
+	v.sequencerScrape(prefix+"scrape", network.Scrape)
+
+	if network.Wallet != nil {
+		v.wallet(prefix+"wallet", network.Wallet)
+	}
+}
+
+func (v *validator) wallet(path string, wallet *Wallet) {
+	v.urls(path+".l1geth", wallet.L1Geth, true, RPCSchemes)
+	v.urls(path+".l2geth", wallet.L2Geth, true, RPCSchemes)
+	v.url(path+".themis", wallet.Themis, false, RESTSchemes)
+	switch {
+	case wallet.L1Quorum < 0 || wallet.L1Quorum == 1:
+		v.addf(path+".l1_quorum", "quorum must be 0 or at least 2")
+	case wallet.L1Quorum > len(wallet.L1Geth):
+		v.addf(path+".l1_quorum", "quorum %d is more than the %d l1geth urls", wallet.L1Quorum, len(wallet.L1Geth))
+	}
+
+	// the mpc aliases are reserved even without themis, so enabling it later doesn't shadow a wallet
+	reserved := make(map[string]bool)
+	for i := themis.CommonMpcAddr; i <= themis.BlobSubmitMpcAddr; i++ {
+		reserved[i.String()] = true
+	}
+
+	for _, alias := range utils.SortedKeys(wallet.Wallets) {
+		v.address(fmt.Sprintf("%s.wallets.%s", path, alias), wallet.Wallets[alias])
+		if reserved[alias] {
+			v.addf(fmt.Sprintf("%s.wallets.%s", path, alias), "alias is reserved for the mpc address")
+		}
+	}
+
+	for _, alias := range utils.SortedKeys(wallet.L2Wallets) {
+		v.address(fmt.Sprintf("%s.l2_wallets.%s", path, alias), wallet.L2Wallets[alias])
+		if reserved[alias] {
+			v.addf(fmt.Sprintf("%s.l2_wallets.%s", path, alias), "alias is reserved for the mpc address")
+		}
+		if _, ok := wallet.Wallets[alias]; ok {
+			v.addf(fmt.Sprintf("%s.l2_wallets.%s", path, alias), "alias is duplicated with %s.wallets.%s", path, alias)
+		}
+	}
+
+	for _, alias := range utils.SortedKeys(wallet.MinBalance) {
+		// the mpc addresses are only known if they're resolved by themis
+		if _, ok := wallet.Wallets[alias]; !ok && !(reserved[alias] && wallet.Themis != "") {
+			v.addf(fmt.Sprintf("%s.min_balance.%s", path, alias), "unknown wallet alias")
+		}
+	}
+
+	for _, alias := range utils.SortedKeys(wallet.L2MinBalance) {
+		if _, ok := wallet.L2Wallets[alias]; !ok && alias != themis.CommonMpcAddr.String() {
+			v.addf(fmt.Sprintf("%s.l2_min_balance.%s", path, alias), "unknown wallet alias")
+		}
+	}
+
+	if wallet.Rollup != nil {
+		v.address(path+".rollup.ctc", wallet.Rollup.CTC)
+		v.address(path+".rollup.scc", wallet.Rollup.SCC)
+	}
+
+	for _, svc := range WalletServices {
+		v.scrape(path+".scrape."+svc, wallet.Scrape.Service(svc))
+	}
+	for _, svc := range WalletEndpoints {
+		v.client(path+".clients."+svc, wallet.Clients.Service(svc))
+	}
+}
+
+func (v *validator) alerting(path string, alerting *Alerting) {
+	names := make(map[string]bool)
+	for i, rule := range alerting.Rules {
+		rulePath := fmt.Sprintf("%s.rules[%d]", path, i)
+		if rule == nil {
+			v.addf(rulePath, "rule is empty")
+			continue
+		}
+		if rule.Name == "" {
+			v.addf(rulePath+".name", "name is required")
+		} else if names[rule.Name] {
+			v.addf(rulePath+".name", "rule %s is duplicated", rule.Name)
+		}
+		names[rule.Name] = true
+
+		switch rule.Type {
+		case AlertChainStalled, AlertWalletBalance, AlertScrapeFailures, AlertSpanEnding, AlertNonceGap:
+		default:
+			v.addf(rulePath+".type", "unknown rule type %q", rule.Type)
+		}
+		if rule.Window < 0 || rule.For < 0 {
+			v.addf(rulePath, "negative duration")
+		}
+	}
+
+	for i, receiver := range alerting.Receivers {
+		receiverPath := fmt.Sprintf("%s.receivers[%d]", path, i)
+		if receiver == nil {
+			v.addf(receiverPath, "receiver is empty")
+			continue
+		}
+		v.url(receiverPath+".url", receiver.URL, true, RESTSchemes)
+		v.client(receiverPath+".client", receiver.Client)
+		switch receiver.Format {
+		case "", ReceiverJSON, ReceiverSlack, ReceiverDiscord:
+		default:
+			v.addf(receiverPath+".format", "unknown format %q", receiver.Format)
+		}
+	}
+}
@@ -13,7 +13,8 @@ type Client struct {
 	httpClient *http.Client
 }
 
-func NewClient(rest string) (*Client, error) {
+// NewClient returns a client of the rest server, nil httpClient for the default one
+func NewClient(rest string, httpClient *http.Client) (*Client, error) {
 	parsed, err := url.Parse(rest)
 	if err != nil {
 		return nil, fmt.Errorf("invalid rest server base url: %s", rest)
@@ -21,9 +22,12 @@ func NewClient(rest string) (*Client, error) {
 	if parsed.Path != "/" {
 		parsed.Path = ""
 	}
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
 	return &Client{
 		restHost:   parsed.String(),
-		httpClient: &http.Client{},
+		httpClient: httpClient,
 	}, nil
 }
 
@@ -0,0 +1,47 @@
+package dtl
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type StateRootEntry struct {
+	Index      uint64      `json:"index"`
+	BatchIndex uint64      `json:"batchIndex"`
+	Value      common.Hash `json:"value"`
+	Confirmed  bool        `json:"confirmed"`
+}
+
+type StateRootBatchEntry struct {
+	Index             uint64         `json:"index"`
+	BlockNumber       uint64         `json:"blockNumber"`
+	Timestamp         uint64         `json:"timestamp"`
+	Submitter         common.Address `json:"submitter"`
+	Size              uint64         `json:"size"`
+	Root              common.Hash    `json:"root"`
+	PrevTotalElements uint64         `json:"prevTotalElements"`
+	L1TransactionHash common.Hash    `json:"l1TransactionHash"`
+}
+
+type StateRootBatchResponse struct {
+	Batch      *StateRootBatchEntry `json:"batch"`
+	StateRoots []*StateRootEntry    `json:"stateRoots"`
+}
+
+func (c *Client) GetLatestStateRootBatch(ctx context.Context) (*StateRootBatchResponse, error) {
+	var res StateRootBatchResponse
+	if err := c.Get(ctx, "/batch/stateroot/latest", &res); err != nil {
+		return nil, fmt.Errorf("DTL: GetLatestStateRootBatch: %w", err)
+	}
+	return &res, nil
+}
+
+func (c *Client) GetStateRootBatchByIndex(ctx context.Context, index uint64) (*StateRootBatchResponse, error) {
+	var res StateRootBatchResponse
+	if err := c.Get(ctx, fmt.Sprintf("/batch/stateroot/index/%d", index), &res); err != nil {
+		return nil, fmt.Errorf("DTL: GetStateRootBatchByIndex: %w", err)
+	}
+	return &res, nil
+}
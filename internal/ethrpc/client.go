@@ -0,0 +1,247 @@
+package ethrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/logging"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/utils"
+)
+
+// Endpoint is a json-rpc url with its dial options
+type Endpoint struct {
+	URL     string
+	Options []rpc.ClientOption
+	// Label is the endpoint label of the metrics, the url host and the index are used if it's empty
+	Label string
+}
+
+// endpoint dials on the first call instead of the creation,
+// so an unreachable endpoint fails the calls rather than the startup.
+// The dial is retried by the following calls until it succeeds.
+type endpoint struct {
+	Endpoint
+
+	mutex  sync.Mutex
+	client *ethclient.Client
+
+	// the health check results, guarded by the mutex of the Client
+	healthy bool
+	head    uint64
+	latency float64
+}
+
+func (e *endpoint) dial(ctx context.Context) (*ethclient.Client, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.client != nil {
+		return e.client, nil
+	}
+
+	rpcClient, err := rpc.DialOptions(ctx, e.URL, e.Options...)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %s", utils.RedactURL(e.URL), err)
+	}
+	e.client = ethclient.NewClient(rpcClient)
+	return e.client, nil
+}
+
+// Client is a lazily dialed ethclient, the calls are sent to the active endpoint
+// and fail over to the other endpoints if the active one is unreachable
+type Client struct {
+	name      string
+	endpoints []*endpoint
+	metrics   *Metrics
+
+	mutex  sync.Mutex
+	active int
+
+	logger *slog.Logger
+}
+
+// New returns a client of the single url which is dialed with the options
+func New(url string, opts ...rpc.ClientOption) *Client {
+	return NewFailover("", []Endpoint{{URL: url, Options: opts}}, nil)
+}
+
+// NewFailover returns a client of the endpoints, the first one is active at the beginning,
+// the name labels the metrics which can be nil
+func NewFailover(name string, endpoints []Endpoint, metrics *Metrics) *Client {
+	c := &Client{name: name, metrics: metrics, logger: logging.New("ethrpc")}
+	for i, ep := range endpoints {
+		if ep.Label == "" {
+			ep.Label = utils.EndpointLabel(ep.URL, i)
+		}
+		c.endpoints = append(c.endpoints, &endpoint{Endpoint: ep, healthy: true})
+	}
+	c.metrics.setActive(c, 0)
+	return c
+}
+
+// Close closes the dialed endpoints
+func (c *Client) Close() {
+	for _, ep := range c.endpoints {
+		ep.mutex.Lock()
+		if ep.client != nil {
+			ep.client.Close()
+			ep.client = nil
+		}
+		ep.mutex.Unlock()
+	}
+}
+
+// Active returns the url of the active endpoint
+func (c *Client) Active() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.endpoints[c.active].URL
+}
+
+// call calls the function with the active endpoint, and retries it with the next endpoint
+// if the active one is unreachable
+func (c *Client) call(ctx context.Context, fn func(*ethclient.Client) error) error {
+	c.mutex.Lock()
+	index := c.active
+	c.mutex.Unlock()
+
+	for tries := 1; ; tries++ {
+		client, err := c.endpoints[index].dial(ctx)
+		if err == nil {
+			err = fn(client)
+		}
+		if err == nil || tries >= len(c.endpoints) || !isEndpointFailure(ctx, err) {
+			return err
+		}
+		index = c.failover(index, err)
+	}
+}
+
+// failover marks the endpoint as unhealthy and activates the next healthy endpoint,
+// or the next one if none is healthy. It returns the active endpoint index.
+func (c *Client) failover(from int, cause error) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.endpoints[from].healthy = false
+	if c.active != from {
+		// the other call has failed over already
+		return c.active
+	}
+
+	next := (from + 1) % len(c.endpoints)
+	for i := 1; i < len(c.endpoints); i++ {
+		index := (from + i) % len(c.endpoints)
+		if c.endpoints[index].healthy {
+			next = index
+			break
+		}
+	}
+
+	c.logger.Warn("rpc failover", "client", c.name, "from", utils.RedactURL(c.endpoints[from].URL),
+		"to", utils.RedactURL(c.endpoints[next].URL), "err", cause)
+	c.activate(next)
+	return next
+}
+
+// activate should be called with the mutex held
+func (c *Client) activate(index int) {
+	if c.active == index {
+		return
+	}
+	c.active = index
+	c.metrics.setActive(c, index)
+	c.metrics.incFailovers(c)
+}
+
+// isEndpointFailure reports whether the error is caused by the endpoint rather than the request
+func isEndpointFailure(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ethereum.NotFound) {
+		return false
+	}
+	// the json-rpc errors are replied by the endpoint
+	var rpcErr rpc.Error
+	return !errors.As(err, &rpcErr)
+}
+
+func (c *Client) BlockNumber(ctx context.Context) (number uint64, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		number, err = client.BlockNumber(ctx)
+		return err
+	})
+	return number, err
+}
+
+func (c *Client) HeaderByNumber(ctx context.Context, number *big.Int) (header *types.Header, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		header, err = client.HeaderByNumber(ctx, number)
+		return err
+	})
+	return header, err
+}
+
+func (c *Client) BlockByNumber(ctx context.Context, number *big.Int) (block *types.Block, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		block, err = client.BlockByNumber(ctx, number)
+		return err
+	})
+	return block, err
+}
+
+func (c *Client) TransactionSender(ctx context.Context, tx *types.Transaction, block common.Hash, index uint) (sender common.Address, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		sender, err = client.TransactionSender(ctx, tx, block, index)
+		return err
+	})
+	return sender, err
+}
+
+func (c *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (receipt *types.Receipt, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		receipt, err = client.TransactionReceipt(ctx, txHash)
+		return err
+	})
+	return receipt, err
+}
+
+func (c *Client) BalanceAt(ctx context.Context, account common.Address, number *big.Int) (balance *big.Int, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		balance, err = client.BalanceAt(ctx, account, number)
+		return err
+	})
+	return balance, err
+}
+
+func (c *Client) NonceAt(ctx context.Context, account common.Address, number *big.Int) (nonce uint64, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		nonce, err = client.NonceAt(ctx, account, number)
+		return err
+	})
+	return nonce, err
+}
+
+func (c *Client) PendingNonceAt(ctx context.Context, account common.Address) (nonce uint64, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		nonce, err = client.PendingNonceAt(ctx, account)
+		return err
+	})
+	return nonce, err
+}
+
+// CallContract implements the ethereum.ContractCaller
+func (c *Client) CallContract(ctx context.Context, call ethereum.CallMsg, number *big.Int) (result []byte, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		result, err = client.CallContract(ctx, call, number)
+		return err
+	})
+	return result, err
+}
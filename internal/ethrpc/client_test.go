@@ -0,0 +1,196 @@
+package ethrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/metis-devops/metis-sequencer-exporter/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newRPCServer returns a json-rpc server which replies the block number to eth_blockNumber
+func newRPCServer(t *testing.T, number string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request: %s", err)
+			return
+		}
+		if req.Method != "eth_blockNumber" {
+			t.Errorf("unexpected method %s", req.Method)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": number})
+	}))
+}
+
+func TestClient_Dial(t *testing.T) {
+	server := newRPCServer(t, "0x64")
+	defer server.Close()
+
+	// a closed port to simulate the unreachable websocket endpoint
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := "ws://" + listener.Addr().String()
+	listener.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		want    uint64
+		wantErr bool
+	}{
+		{
+			name: "ok",
+			url:  server.URL,
+			want: 100,
+		},
+		{
+			name:    "unreachable",
+			url:     unreachable,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := New(tt.url)
+			for i := 0; i < 2; i++ {
+				got, err := client.BlockNumber(context.Background())
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Client.BlockNumber() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if got != tt.want {
+					t.Errorf("Client.BlockNumber() = %v, want %v", got, tt.want)
+				}
+			}
+			if connected := client.endpoints[0].client != nil; connected == tt.wantErr {
+				t.Errorf("Client connected = %v, wantErr %v", connected, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClient_Failover(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	backup := newRPCServer(t, "0x64")
+	defer backup.Close()
+
+	metrics := NewMetrics(prometheus.NewRegistry())
+	client := NewFailover("l1geth", []Endpoint{{URL: primary.URL}, {URL: backup.URL}}, metrics)
+
+	got, err := client.BlockNumber(context.Background())
+	if err != nil {
+		t.Fatalf("Client.BlockNumber() error = %v", err)
+	}
+	if got != 100 {
+		t.Errorf("Client.BlockNumber() = %v, want 100", got)
+	}
+	if active := client.Active(); active != backup.URL {
+		t.Errorf("Client.Active() = %s, want %s", active, backup.URL)
+	}
+	if got := testutil.ToFloat64(metrics.failovers); got != 1 {
+		t.Errorf("failovers = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.active.WithLabelValues("l1geth", utils.EndpointLabel(backup.URL, 1))); got != 1 {
+		t.Errorf("active = %v, want 1", got)
+	}
+
+	// the health check keeps the healthy backup active
+	client.healthCheck(context.Background(), time.Second)
+	if active := client.Active(); active != backup.URL {
+		t.Errorf("Client.Active() after health check = %s, want %s", active, backup.URL)
+	}
+}
+
+func TestMetrics_setActive(t *testing.T) {
+	// the urls differ only in the api keys, which are redacted in the logs
+	metrics := NewMetrics(prometheus.NewRegistry())
+	NewFailover("l1geth", []Endpoint{
+		{URL: "https://mainnet.infura.io/v3/0123456789abcdef0123456789abcdef"},
+		{URL: "https://mainnet.infura.io/v3/fedcba9876543210fedcba9876543210"},
+	}, metrics)
+
+	if got := testutil.CollectAndCount(metrics.active); got != 2 {
+		t.Errorf("active series = %d, want 2", got)
+	}
+	if got := testutil.ToFloat64(metrics.active.WithLabelValues("l1geth", "mainnet.infura.io#1")); got != 0 {
+		t.Errorf("active of the backup = %v, want 0", got)
+	}
+
+	// the labels of the transport pool take precedence over the indexes
+	NewFailover("l2geth", []Endpoint{
+		{URL: "https://mainnet.infura.io/v3/fedcba9876543210fedcba9876543210", Label: "mainnet.infura.io#1"},
+	}, metrics)
+	if got := testutil.ToFloat64(metrics.active.WithLabelValues("l2geth", "mainnet.infura.io#1")); got != 1 {
+		t.Errorf("active of the labeled endpoint = %v, want 1", got)
+	}
+}
+
+func TestClient_Healthiest(t *testing.T) {
+	tests := []struct {
+		name      string
+		active    int
+		endpoints []*endpoint
+		want      int
+	}{
+		{
+			name:   "sticky",
+			active: 0,
+			endpoints: []*endpoint{
+				{healthy: true, head: 100, latency: 0.5},
+				{healthy: true, head: 102, latency: 0.1},
+			},
+			want: 0,
+		},
+		{
+			name:   "lagging",
+			active: 0,
+			endpoints: []*endpoint{
+				{healthy: true, head: 90, latency: 0.1},
+				{healthy: true, head: 100, latency: 0.5},
+				{healthy: true, head: 99, latency: 0.3},
+			},
+			want: 2,
+		},
+		{
+			name:   "unhealthy",
+			active: 1,
+			endpoints: []*endpoint{
+				{healthy: true, head: 100, latency: 0.5},
+				{healthy: false},
+			},
+			want: 0,
+		},
+		{
+			name:   "none",
+			active: 0,
+			endpoints: []*endpoint{
+				{healthy: false},
+				{healthy: false},
+			},
+			want: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{endpoints: tt.endpoints, active: tt.active}
+			if got := client.healthiest(); got != tt.want {
+				t.Errorf("Client.healthiest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
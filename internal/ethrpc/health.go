@@ -0,0 +1,177 @@
+package ethrpc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MaxLagBlocks is how far an endpoint can be behind the highest head and still be considered healthy
+const MaxLagBlocks = 5
+
+// Metrics is the failover metrics of the clients
+type Metrics struct {
+	active        *prometheus.GaugeVec
+	failovers     *prometheus.CounterVec
+	disagreements *prometheus.CounterVec
+	quorumErrors  *prometheus.CounterVec
+}
+
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		active: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "metis_sequencer_exporter_rpc_active",
+			Help: "Whether the endpoint is the active one of the rpc client, 1 for active. The endpoint is the host and the index of the url.",
+		}, []string{"client", "endpoint"}),
+		failovers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "metis_sequencer_exporter_rpc_failovers",
+			Help: "Number of times the rpc client switched the active endpoint.",
+		}, []string{"client"}),
+		disagreements: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "metis_sequencer_exporter_rpc_disagreements",
+			Help: "Number of quorum reads which the endpoints of the rpc client replied different values to.",
+		}, []string{"client", "method"}),
+		quorumErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "metis_sequencer_exporter_rpc_quorum_errors",
+			Help: "Number of endpoint errors in the quorum reads of the rpc client, the read succeeds if the rest still agree.",
+		}, []string{"client", "method"}),
+	}
+	reg.MustRegister(m.active, m.failovers, m.disagreements, m.quorumErrors)
+	return m
+}
+
+func (m *Metrics) setActive(c *Client, active int) {
+	if m == nil {
+		return
+	}
+	for i, ep := range c.endpoints {
+		var value float64
+		if i == active {
+			value = 1
+		}
+		m.active.With(prometheus.Labels{"client": c.name, "endpoint": ep.Label}).Set(value)
+	}
+	m.failovers.With(prometheus.Labels{"client": c.name}).Add(0)
+}
+
+func (m *Metrics) incFailovers(c *Client) {
+	if m == nil {
+		return
+	}
+	m.failovers.With(prometheus.Labels{"client": c.name}).Inc()
+}
+
+func (m *Metrics) incDisagreements(c *Client, method string) {
+	if m == nil {
+		return
+	}
+	m.disagreements.With(prometheus.Labels{"client": c.name, "method": method}).Inc()
+}
+
+func (m *Metrics) incQuorumErrors(c *Client, method string) {
+	if m == nil {
+		return
+	}
+	m.quorumErrors.With(prometheus.Labels{"client": c.name, "method": method}).Inc()
+}
+
+// Run health checks the endpoints every interval until the context is done,
+// it's a no-op for the client of the single endpoint
+func (c *Client) Run(ctx context.Context, interval time.Duration) {
+	if len(c.endpoints) < 2 {
+		return
+	}
+
+	ticker := time.NewTimer(0)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.healthCheck(ctx, interval)
+			ticker.Reset(interval)
+		}
+	}
+}
+
+// Ping gets the head of the endpoint of the index, which is the order of the endpoints of NewFailover
+func (c *Client) Ping(ctx context.Context, index int) (uint64, error) {
+	client, err := c.endpoints[index].dial(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return client.BlockNumber(ctx)
+}
+
+// healthCheck gets the head of every endpoint and activates the healthiest one
+func (c *Client) healthCheck(basectx context.Context, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(basectx, timeout)
+	defer cancel()
+
+	type result struct {
+		head    uint64
+		latency time.Duration
+		err     error
+	}
+	results := make([]result, len(c.endpoints))
+
+	var wg sync.WaitGroup
+	for i := range c.endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			head, err := c.Ping(ctx, i)
+			results[i].head, results[i].latency, results[i].err = head, time.Since(start), err
+		}()
+	}
+	wg.Wait()
+
+	if basectx.Err() != nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i, ep := range c.endpoints {
+		ep.healthy = results[i].err == nil
+		ep.head = results[i].head
+		ep.latency = results[i].latency.Seconds()
+	}
+	if best := c.healthiest(); best >= 0 && best != c.active {
+		c.activate(best)
+	}
+}
+
+// healthiest returns the active endpoint if it's healthy and not lagging behind,
+// otherwise the fastest one of such endpoints, -1 if none is healthy.
+// It should be called with the mutex held.
+func (c *Client) healthiest() int {
+	var highest uint64
+	for _, ep := range c.endpoints {
+		if ep.healthy {
+			highest = max(highest, ep.head)
+		}
+	}
+
+	candidate := func(ep *endpoint) bool {
+		return ep.healthy && ep.head+MaxLagBlocks >= highest
+	}
+
+	if candidate(c.endpoints[c.active]) {
+		return c.active
+	}
+
+	best := -1
+	for i, ep := range c.endpoints {
+		if candidate(ep) && (best < 0 || ep.latency < c.endpoints[best].latency) {
+			best = i
+		}
+	}
+	return best
+}
@@ -0,0 +1,170 @@
+package ethrpc
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/utils"
+)
+
+// QuorumHead returns the highest block number which most of the size endpoints have,
+// and checks they agree on its hash. The following quorum reads should be pinned to it.
+func (c *Client) QuorumHead(ctx context.Context, size int) (uint64, error) {
+	endpoints := c.quorumEndpoints(size)
+
+	replies, err := each(ctx, c, endpoints, "eth_blockNumber", func(client *ethclient.Client) (uint64, error) {
+		return client.BlockNumber(ctx)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	heads := slices.SortedFunc(maps.Values(replies), func(a, b uint64) int { return cmp.Compare(b, a) })
+	// the heads are never exactly the same, only the stale endpoint is a disagreement
+	if heads[0]-heads[len(heads)-1] > MaxLagBlocks {
+		disagree(c, "eth_blockNumber", replies, func(head uint64) string { return strconv.FormatUint(head, 10) })
+	}
+
+	majority := len(endpoints)/2 + 1
+	if len(heads) < majority {
+		return 0, fmt.Errorf("only %d of %d endpoints replied eth_blockNumber", len(heads), len(endpoints))
+	}
+	// the highest head which most of the endpoints have, the ones behind it are left out of the hash check
+	head := heads[majority-1]
+	var current []*endpoint
+	for ep, number := range replies {
+		if number >= head {
+			current = append(current, ep)
+		}
+	}
+
+	number := new(big.Int).SetUint64(head)
+	_, err = quorum(ctx, c, current, len(endpoints), "eth_getBlockByNumber", func(client *ethclient.Client) (*types.Header, error) {
+		return client.HeaderByNumber(ctx, number)
+	}, func(header *types.Header) string { return header.Hash().Hex() })
+	if err != nil {
+		return 0, err
+	}
+	return head, nil
+}
+
+// QuorumBalanceAt returns the balance which most of the size endpoints agree on
+func (c *Client) QuorumBalanceAt(ctx context.Context, size int, account common.Address, number *big.Int) (*big.Int, error) {
+	endpoints := c.quorumEndpoints(size)
+	return quorum(ctx, c, endpoints, len(endpoints), "eth_getBalance", func(client *ethclient.Client) (*big.Int, error) {
+		return client.BalanceAt(ctx, account, number)
+	}, (*big.Int).String)
+}
+
+// QuorumNonceAt returns the nonce which most of the size endpoints agree on
+func (c *Client) QuorumNonceAt(ctx context.Context, size int, account common.Address, number *big.Int) (uint64, error) {
+	endpoints := c.quorumEndpoints(size)
+	return quorum(ctx, c, endpoints, len(endpoints), "eth_getTransactionCount", func(client *ethclient.Client) (uint64, error) {
+		return client.NonceAt(ctx, account, number)
+	}, func(nonce uint64) string { return strconv.FormatUint(nonce, 10) })
+}
+
+// quorumEndpoints returns the size endpoints to read from, the active and the healthy ones first
+func (c *Client) quorumEndpoints(size int) []*endpoint {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	endpoints := []*endpoint{c.endpoints[c.active]}
+	for _, healthy := range []bool{true, false} {
+		for i, ep := range c.endpoints {
+			if i != c.active && ep.healthy == healthy {
+				endpoints = append(endpoints, ep)
+			}
+		}
+	}
+	return endpoints[:min(max(size, 1), len(endpoints))]
+}
+
+// disagree counts and logs the replies of the endpoints which are not the same
+func disagree[T any](c *Client, method string, replies map[*endpoint]T, key func(T) string) {
+	c.metrics.incDisagreements(c, method)
+
+	attrs := []any{"client", c.name, "method", method}
+	for ep, reply := range replies {
+		attrs = append(attrs, utils.RedactURL(ep.URL), key(reply))
+	}
+	c.logger.Warn("rpc disagreement", attrs...)
+}
+
+// quorum reads from the endpoints and returns the reply which more than half of the size endpoints agree on,
+// the failed endpoints are counted as the quorum errors and fail the read only if the rest have no majority
+func quorum[T any](ctx context.Context, c *Client, endpoints []*endpoint, size int, method string, fn func(*ethclient.Client) (T, error), key func(T) string) (T, error) {
+	var zero T
+
+	replies, err := each(ctx, c, endpoints, method, fn)
+	if err != nil {
+		return zero, err
+	}
+
+	counts := make(map[string]int)
+	var best T
+	var bestKey string
+	for _, reply := range replies {
+		k := key(reply)
+		counts[k]++
+		if counts[k] > counts[bestKey] {
+			best, bestKey = reply, k
+		}
+	}
+
+	if len(counts) > 1 {
+		disagree(c, method, replies, key)
+	}
+
+	if counts[bestKey]*2 <= size {
+		return zero, fmt.Errorf("no majority of %d endpoints agree on %s, %d replied", size, method, len(replies))
+	}
+	return best, nil
+}
+
+// each calls the function with every endpoint concurrently and returns the replies of the succeeded ones,
+// the failed ones are counted and logged, and it fails only if all of them fail
+func each[T any](ctx context.Context, c *Client, endpoints []*endpoint, method string, fn func(*ethclient.Client) (T, error)) (map[*endpoint]T, error) {
+	replies := make([]T, len(endpoints))
+	errs := make([]error, len(endpoints))
+
+	var wg sync.WaitGroup
+	for i, ep := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, err := ep.dial(ctx)
+			if err == nil {
+				replies[i], err = fn(client)
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+
+	succeeded := make(map[*endpoint]T)
+	var failed []error
+	for i, err := range errs {
+		if err != nil {
+			c.metrics.incQuorumErrors(c, method)
+			c.logger.Warn("rpc quorum error", "client", c.name, "method", method, "endpoint", utils.RedactURL(endpoints[i].URL), "err", err)
+			failed = append(failed, fmt.Errorf("%s: %s", utils.RedactURL(endpoints[i].URL), err))
+			continue
+		}
+		succeeded[endpoints[i]] = replies[i]
+	}
+	if len(succeeded) == 0 {
+		return nil, errors.Join(failed...)
+	}
+	return succeeded, nil
+}
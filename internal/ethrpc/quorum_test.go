@@ -0,0 +1,206 @@
+package ethrpc
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// provider is the chain state which the quorum server replies
+type provider struct {
+	head    uint64
+	extra   string
+	balance int64
+	// failed replies an error to every request
+	failed bool
+}
+
+func newQuorumServer(t *testing.T, p provider) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request: %s", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if p.failed {
+			_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "error": map[string]any{"code": -32000, "message": "unavailable"}})
+			return
+		}
+
+		var result any
+		switch req.Method {
+		case "eth_blockNumber":
+			result = hexutil.Uint64(p.head)
+		case "eth_getBlockByNumber":
+			var number hexutil.Uint64
+			if err := json.Unmarshal(req.Params[0], &number); err != nil {
+				t.Errorf("invalid block number %s: %s", req.Params[0], err)
+			}
+			if uint64(number) > p.head {
+				t.Errorf("block %d is read from the endpoint behind it", number)
+			}
+			result = &types.Header{
+				Number:     new(big.Int).SetUint64(uint64(number)),
+				Difficulty: common.Big0,
+				Extra:      []byte(p.extra),
+			}
+		case "eth_getBalance":
+			if string(req.Params[1]) == `"latest"` {
+				t.Errorf("balance is not pinned to the block number")
+			}
+			result = (*hexutil.Big)(big.NewInt(p.balance))
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+}
+
+func newQuorumClient(t *testing.T, providers []provider) (*Client, *Metrics) {
+	var endpoints []Endpoint
+	for _, p := range providers {
+		server := newQuorumServer(t, p)
+		t.Cleanup(server.Close)
+		endpoints = append(endpoints, Endpoint{URL: server.URL})
+	}
+	metrics := NewMetrics(prometheus.NewRegistry())
+	return NewFailover("l1geth", endpoints, metrics), metrics
+}
+
+func TestClient_QuorumHead(t *testing.T) {
+	tests := []struct {
+		name              string
+		providers         []provider
+		want              uint64
+		wantErr           bool
+		wantDisagreements map[string]float64
+	}{
+		{
+			name:      "agreed",
+			providers: []provider{{head: 100}, {head: 102}, {head: 101}},
+			want:      101,
+		},
+		{
+			name:              "stale",
+			providers:         []provider{{head: 120}, {head: 100}, {head: 120}},
+			want:              120,
+			wantDisagreements: map[string]float64{"eth_blockNumber": 1},
+		},
+		{
+			name:      "failed",
+			providers: []provider{{head: 100, failed: true}, {head: 100}, {head: 101}},
+			want:      100,
+		},
+		{
+			name:      "failed-majority",
+			providers: []provider{{head: 100, failed: true}, {head: 100, failed: true}, {head: 101}},
+			wantErr:   true,
+		},
+		{
+			name:              "forked",
+			providers:         []provider{{head: 100}, {head: 100, extra: "fork"}},
+			wantErr:           true,
+			wantDisagreements: map[string]float64{"eth_getBlockByNumber": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, metrics := newQuorumClient(t, tt.providers)
+
+			got, err := client.QuorumHead(context.Background(), len(tt.providers))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Client.QuorumHead() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Client.QuorumHead() = %v, want %v", got, tt.want)
+			}
+			for _, method := range []string{"eth_blockNumber", "eth_getBlockByNumber"} {
+				if got := testutil.ToFloat64(metrics.disagreements.WithLabelValues("l1geth", method)); got != tt.wantDisagreements[method] {
+					t.Errorf("disagreements of %s = %v, want %v", method, got, tt.wantDisagreements[method])
+				}
+			}
+		})
+	}
+}
+
+func TestClient_QuorumBalanceAt(t *testing.T) {
+	tests := []struct {
+		name              string
+		providers         []provider
+		size              int
+		want              int64
+		wantErr           bool
+		wantDisagreements float64
+		wantQuorumErrors  float64
+	}{
+		{
+			name:      "agreed",
+			providers: []provider{{balance: 10}, {balance: 10}, {balance: 20}},
+			size:      2,
+			want:      10,
+		},
+		{
+			name:              "majority",
+			providers:         []provider{{balance: 10}, {balance: 20}, {balance: 10}},
+			size:              3,
+			want:              10,
+			wantDisagreements: 1,
+		},
+		{
+			name:              "no-majority",
+			providers:         []provider{{balance: 10}, {balance: 20}},
+			size:              2,
+			wantErr:           true,
+			wantDisagreements: 1,
+		},
+		{
+			name:             "failed",
+			providers:        []provider{{balance: 10}, {failed: true}, {balance: 10}},
+			size:             3,
+			want:             10,
+			wantQuorumErrors: 1,
+		},
+		{
+			name:              "failed-no-majority",
+			providers:         []provider{{balance: 10}, {failed: true}, {balance: 20}},
+			size:              3,
+			wantErr:           true,
+			wantDisagreements: 1,
+			wantQuorumErrors:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, metrics := newQuorumClient(t, tt.providers)
+
+			got, err := client.QuorumBalanceAt(context.Background(), tt.size, common.HexToAddress("0x01"), big.NewInt(100))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Client.QuorumBalanceAt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Int64() != tt.want {
+				t.Errorf("Client.QuorumBalanceAt() = %v, want %v", got, tt.want)
+			}
+			if got := testutil.ToFloat64(metrics.disagreements.WithLabelValues("l1geth", "eth_getBalance")); got != tt.wantDisagreements {
+				t.Errorf("disagreements = %v, want %v", got, tt.wantDisagreements)
+			}
+			if got := testutil.ToFloat64(metrics.quorumErrors.WithLabelValues("l1geth", "eth_getBalance")); got != tt.wantQuorumErrors {
+				t.Errorf("quorum errors = %v, want %v", got, tt.wantQuorumErrors)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// Package logging builds the loggers of the modules with the level and the format of the flags,
+// the per-module levels of the config and the rate limit of the repeated errors
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metis-devops/metis-sequencer-exporter/internal/utils"
+)
+
+// RepeatWindow is the interval the identical errors are logged at most once in,
+// the suppressed ones are counted on the next logged record
+const RepeatWindow = 5 * time.Minute
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var (
+	mutex   sync.Mutex
+	level   slog.Level
+	format  = FormatText
+	levels  map[string]slog.Level
+	output  io.Writer = os.Stdout
+	limiter           = newRepeatLimiter(RepeatWindow)
+)
+
+// Setup sets the default level, the format and the module levels of the loggers created afterwards,
+// and replaces the default logger, which writes to the same output as the module loggers
+func Setup(defaultLevel slog.Level, logFormat string, moduleLevels map[string]slog.Level) error {
+	if logFormat != FormatText && logFormat != FormatJSON {
+		return fmt.Errorf("unsupported log format %q, expected one of %s, %s", logFormat, FormatText, FormatJSON)
+	}
+
+	mutex.Lock()
+	level, format, levels = defaultLevel, logFormat, moduleLevels
+	mutex.Unlock()
+
+	slog.SetDefault(slog.New(newHandler(output, defaultLevel)))
+	return nil
+}
+
+// New returns the logger of the module, it logs at the level of the module if it's set in the config
+func New(module string) *slog.Logger {
+	mutex.Lock()
+	moduleLevel, ok := levels[module]
+	if !ok {
+		moduleLevel = level
+	}
+	mutex.Unlock()
+
+	return slog.New(newHandler(output, moduleLevel)).With("module", module)
+}
+
+func newHandler(w io.Writer, minLevel slog.Level) slog.Handler {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	opts := &slog.HandlerOptions{Level: minLevel, ReplaceAttr: utils.RedactAttr}
+	var base slog.Handler
+	if format == FormatJSON {
+		base = slog.NewJSONHandler(w, opts)
+	} else {
+		base = slog.NewTextHandler(w, opts)
+	}
+	return &handler{Handler: base, limiter: limiter}
+}
+
+// handler drops the errors which are identical to the ones logged within the repeat window
+type handler struct {
+	slog.Handler
+
+	// key is the attrs and the groups of the handler, which identify the errors with the records
+	key     string
+	limiter *repeatLimiter
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelError {
+		var key strings.Builder
+		key.WriteString(h.key)
+		key.WriteString(r.Message)
+		r.Attrs(func(a slog.Attr) bool {
+			fmt.Fprintf(&key, " %s", a)
+			return true
+		})
+
+		suppressed, ok := h.limiter.allow(key.String(), r.Time)
+		if !ok {
+			return nil
+		}
+		if suppressed > 0 {
+			r = r.Clone()
+			r.AddAttrs(slog.Int("suppressed", suppressed))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	key := h.key
+	for _, a := range attrs {
+		key += a.String() + " "
+	}
+	return &handler{Handler: h.Handler.WithAttrs(attrs), key: key, limiter: h.limiter}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{Handler: h.Handler.WithGroup(name), key: h.key + name + ".", limiter: h.limiter}
+}
+
+// repeatLimiter allows a key once per window and counts the suppressed ones
+type repeatLimiter struct {
+	window time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]*repeatEntry
+}
+
+type repeatEntry struct {
+	loggedAt   time.Time
+	suppressed int
+}
+
+func newRepeatLimiter(window time.Duration) *repeatLimiter {
+	return &repeatLimiter{window: window, entries: make(map[string]*repeatEntry)}
+}
+
+// allow reports whether the key should be logged at the time,
+// and returns the number of the suppressed ones since it was logged last time
+func (l *repeatLimiter) allow(key string, now time.Time) (int, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	entry, ok := l.entries[key]
+	if ok && now.Sub(entry.loggedAt) < l.window {
+		entry.suppressed++
+		return 0, false
+	}
+
+	var suppressed int
+	if ok {
+		suppressed = entry.suppressed
+	} else {
+		l.prune(now)
+	}
+	l.entries[key] = &repeatEntry{loggedAt: now}
+	return suppressed, true
+}
+
+// prune drops the expired entries, so the errors which are not repeated don't pile up,
+// the entries which have suppressed ones are kept until the count is logged with the key
+func (l *repeatLimiter) prune(now time.Time) {
+	for key, entry := range l.entries {
+		if now.Sub(entry.loggedAt) >= l.window && entry.suppressed == 0 {
+			delete(l.entries, key)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/metis-devops/metis-sequencer-exporter/internal/logging"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const scopeName = "github.com/metis-devops/metis-sequencer-exporter"
+
+// Pusher pushes the metrics of a prometheus registry to an OTLP/HTTP collector
+type Pusher struct {
+	endpoint   string
+	headers    map[string]string
+	resource   *Resource
+	gatherer   prometheus.Gatherer
+	httpClient *http.Client
+	startTime  time.Time
+	logger     *slog.Logger
+}
+
+// NewPusher returns a pusher of the collector endpoint, nil httpClient for the default one
+func NewPusher(endpoint string, headers, attributes map[string]string, gatherer prometheus.Gatherer, httpClient *http.Client) *Pusher {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return &Pusher{
+		endpoint:   endpoint,
+		headers:    headers,
+		resource:   &Resource{Attributes: toAttributes(attributes)},
+		gatherer:   gatherer,
+		httpClient: httpClient,
+		startTime:  time.Now(),
+		logger:     logging.New("otlp"),
+	}
+}
+
+// Run pushes the metrics periodically until the context is done
+func (p *Pusher) Run(basectx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-basectx.Done():
+			return
+		case <-ticker.C:
+			newctx, cancel := context.WithTimeout(basectx, interval)
+			if err := p.Push(newctx); err != nil {
+				p.logger.Error("push metrics", "endpoint", utils.RedactURL(p.endpoint), "err", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Push gathers the metrics and sends them to the collector once
+func (p *Pusher) Push(ctx context.Context) error {
+	families, err := p.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("gather: %w", err)
+	}
+
+	payload, err := json.Marshal(p.convert(families, time.Now()))
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range p.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("otlp collector error: code %d msg %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+func (p *Pusher) convert(families []*dto.MetricFamily, now time.Time) *ExportMetricsServiceRequest {
+	var (
+		metrics   = make([]*Metric, 0, len(families))
+		timestamp = uint64(now.UnixNano())
+		startTime = uint64(p.startTime.UnixNano())
+	)
+
+	for _, family := range families {
+		metric := &Metric{Name: family.GetName(), Description: family.GetHelp(), Unit: family.GetUnit()}
+
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			metric.Sum = &Sum{AggregationTemporality: aggregationTemporalityCumulative, IsMonotonic: true}
+			for _, m := range family.GetMetric() {
+				metric.Sum.DataPoints = append(metric.Sum.DataPoints, &NumberDataPoint{
+					Attributes:        labelsToAttributes(m.GetLabel()),
+					StartTimeUnixNano: startTime,
+					TimeUnixNano:      timestamp,
+					AsDouble:          Double(m.GetCounter().GetValue()),
+				})
+			}
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			metric.Gauge = &Gauge{}
+			for _, m := range family.GetMetric() {
+				value := m.GetGauge().GetValue()
+				if family.GetType() == dto.MetricType_UNTYPED {
+					value = m.GetUntyped().GetValue()
+				}
+				metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, &NumberDataPoint{
+					Attributes:   labelsToAttributes(m.GetLabel()),
+					TimeUnixNano: timestamp,
+					AsDouble:     Double(value),
+				})
+			}
+		case dto.MetricType_HISTOGRAM:
+			metric.Histogram = &Histogram{AggregationTemporality: aggregationTemporalityCumulative}
+			for _, m := range family.GetMetric() {
+				metric.Histogram.DataPoints = append(metric.Histogram.DataPoints,
+					histogramDataPoint(m, startTime, timestamp))
+			}
+		case dto.MetricType_SUMMARY:
+			metric.Summary = &Summary{}
+			for _, m := range family.GetMetric() {
+				point := &SummaryDataPoint{
+					Attributes:        labelsToAttributes(m.GetLabel()),
+					StartTimeUnixNano: startTime,
+					TimeUnixNano:      timestamp,
+					Count:             m.GetSummary().GetSampleCount(),
+					Sum:               Double(m.GetSummary().GetSampleSum()),
+				}
+				for _, q := range m.GetSummary().GetQuantile() {
+					point.QuantileValues = append(point.QuantileValues,
+						&ValueAtQuantile{Quantile: q.GetQuantile(), Value: Double(q.GetValue())})
+				}
+				metric.Summary.DataPoints = append(metric.Summary.DataPoints, point)
+			}
+		default:
+			p.logger.Warn("unsupported metric type", "name", family.GetName(), "type", family.GetType())
+			continue
+		}
+
+		metrics = append(metrics, metric)
+	}
+
+	return &ExportMetricsServiceRequest{
+		ResourceMetrics: []*ResourceMetrics{
+			{
+				Resource: p.resource,
+				ScopeMetrics: []*ScopeMetrics{
+					{Scope: &InstrumentationScope{Name: scopeName}, Metrics: metrics},
+				},
+			},
+		},
+	}
+}
+
+// histogramDataPoint converts the cumulative prometheus buckets to the OTLP bucket counts,
+// the +Inf bucket is implied by the explicit bounds
+func histogramDataPoint(m *dto.Metric, startTime, timestamp uint64) *HistogramDataPoint {
+	h := m.GetHistogram()
+	point := &HistogramDataPoint{
+		Attributes:        labelsToAttributes(m.GetLabel()),
+		StartTimeUnixNano: startTime,
+		TimeUnixNano:      timestamp,
+		Count:             h.GetSampleCount(),
+		Sum:               Double(h.GetSampleSum()),
+	}
+
+	var prev uint64
+	for _, b := range h.GetBucket() {
+		if math.IsInf(b.GetUpperBound(), 1) {
+			continue
+		}
+		point.ExplicitBounds = append(point.ExplicitBounds, b.GetUpperBound())
+		point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(b.GetCumulativeCount()-prev, 10))
+		prev = b.GetCumulativeCount()
+	}
+	point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(h.GetSampleCount()-prev, 10))
+	return point
+}
+
+func labelsToAttributes(labels []*dto.LabelPair) []*KeyValue {
+	attrs := make([]*KeyValue, 0, len(labels))
+	for _, label := range labels {
+		attrs = append(attrs, &KeyValue{Key: label.GetName(), Value: &AnyValue{StringValue: label.GetValue()}})
+	}
+	return attrs
+}
+
+func toAttributes(values map[string]string) []*KeyValue {
+	attrs := make([]*KeyValue, 0, len(values))
+	for key, value := range values {
+		attrs = append(attrs, &KeyValue{Key: key, Value: &AnyValue{StringValue: value}})
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
+	return attrs
+}
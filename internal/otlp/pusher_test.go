@@ -0,0 +1,122 @@
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/transport"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPusher_Push(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_counter", Help: "counter"}, []string{"seq_name"})
+	counter.WithLabelValues("node-0").Add(3)
+
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test:gauge", Help: "gauge"})
+	gauge.Set(math.Inf(1))
+
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_histogram", Help: "histogram", Buckets: []float64{1, 2}})
+	histogram.Observe(0.5)
+	histogram.Observe(1.5)
+	histogram.Observe(5)
+
+	reg.MustRegister(counter, gauge, histogram)
+
+	var got ExportMetricsServiceRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method should be POST, but got %s", r.Method)
+			return
+		}
+		if r.URL.Path != "/v1/metrics" {
+			t.Errorf("expected url path /v1/metrics got url path %s", r.URL.Path)
+			return
+		}
+		if header := r.Header.Get("content-type"); header != "application/json" {
+			t.Errorf("expected content-type header application/json but got %q", header)
+			return
+		}
+		if header := r.Header.Get("authorization"); header != "Bearer token" {
+			t.Errorf("expected authorization header but got %q", header)
+			return
+		}
+		if header := r.Header.Get("x-scope-orgid"); header != "tenant" {
+			t.Errorf("expected the header of the client config but got %q", header)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("couldn't decode the request %s", err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	httpClient, err := transport.NewHTTPClient(&config.HTTPClient{Headers: map[string]string{"X-Scope-OrgID": "tenant"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pusher := NewPusher(server.URL+"/v1/metrics",
+		map[string]string{"Authorization": "Bearer token"},
+		map[string]string{"service.name": "metis-sequencer-exporter", "deployment.environment": "test"},
+		reg, httpClient)
+	if err := pusher.Push(context.Background()); err != nil {
+		t.Fatalf("Pusher.Push() error = %v", err)
+	}
+
+	if len(got.ResourceMetrics) != 1 || len(got.ResourceMetrics[0].ScopeMetrics) != 1 {
+		t.Fatalf("Pusher.Push() unexpected payload %+v", got)
+	}
+
+	wantAttrs := []*KeyValue{
+		{Key: "deployment.environment", Value: &AnyValue{StringValue: "test"}},
+		{Key: "service.name", Value: &AnyValue{StringValue: "metis-sequencer-exporter"}},
+	}
+	if attrs := got.ResourceMetrics[0].Resource.Attributes; !reflect.DeepEqual(attrs, wantAttrs) {
+		t.Errorf("Pusher.Push() resource attributes = %v, want %v", attrs, wantAttrs)
+	}
+
+	metrics := make(map[string]*Metric)
+	for _, m := range got.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+
+	if m := metrics["test_counter"]; m == nil || m.Sum == nil || !m.Sum.IsMonotonic ||
+		m.Sum.DataPoints[0].AsDouble != 3 || m.Sum.DataPoints[0].Attributes[0].Value.StringValue != "node-0" {
+		t.Errorf("Pusher.Push() unexpected counter %+v", m)
+	}
+
+	if m := metrics["test:gauge"]; m == nil || m.Gauge == nil || !math.IsInf(float64(m.Gauge.DataPoints[0].AsDouble), 1) {
+		t.Errorf("Pusher.Push() unexpected gauge %+v", m)
+	}
+
+	if m := metrics["test_histogram"]; m == nil || m.Histogram == nil {
+		t.Errorf("Pusher.Push() histogram is missing")
+	} else {
+		point := m.Histogram.DataPoints[0]
+		if !reflect.DeepEqual(point.BucketCounts, []string{"1", "1", "1"}) ||
+			!reflect.DeepEqual(point.ExplicitBounds, []float64{1, 2}) || point.Count != 3 {
+			t.Errorf("Pusher.Push() unexpected histogram %+v", point)
+		}
+	}
+}
+
+func TestPusher_PushError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	pusher := NewPusher(server.URL, nil, nil, prometheus.NewRegistry(), nil)
+	if err := pusher.Push(context.Background()); err == nil {
+		t.Errorf("Pusher.Push() expected error")
+	}
+}
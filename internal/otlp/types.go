@@ -0,0 +1,139 @@
+package otlp
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// The OTLP/HTTP JSON encoding of ExportMetricsServiceRequest,
+// see https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/metrics/v1/metrics.proto
+
+const aggregationTemporalityCumulative = 2
+
+// Double is a float64 which encodes the special values as strings like protobuf JSON does
+type Double float64
+
+func (d Double) MarshalJSON() ([]byte, error) {
+	switch v := float64(d); {
+	case math.IsNaN(v):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(v, 1):
+		return []byte(`"Infinity"`), nil
+	case math.IsInf(v, -1):
+		return []byte(`"-Infinity"`), nil
+	default:
+		return json.Marshal(v)
+	}
+}
+
+func (d *Double) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case `"NaN"`:
+		*d = Double(math.NaN())
+	case `"Infinity"`:
+		*d = Double(math.Inf(1))
+	case `"-Infinity"`:
+		*d = Double(math.Inf(-1))
+	default:
+		var v float64
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		*d = Double(v)
+	}
+	return nil
+}
+
+type ExportMetricsServiceRequest struct {
+	ResourceMetrics []*ResourceMetrics `json:"resourceMetrics"`
+}
+
+type ResourceMetrics struct {
+	Resource     *Resource       `json:"resource"`
+	ScopeMetrics []*ScopeMetrics `json:"scopeMetrics"`
+}
+
+type Resource struct {
+	Attributes []*KeyValue `json:"attributes"`
+}
+
+type ScopeMetrics struct {
+	Scope   *InstrumentationScope `json:"scope"`
+	Metrics []*Metric             `json:"metrics"`
+}
+
+type InstrumentationScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type KeyValue struct {
+	Key   string    `json:"key"`
+	Value *AnyValue `json:"value"`
+}
+
+type AnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type Metric struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Unit        string     `json:"unit,omitempty"`
+	Gauge       *Gauge     `json:"gauge,omitempty"`
+	Sum         *Sum       `json:"sum,omitempty"`
+	Histogram   *Histogram `json:"histogram,omitempty"`
+	Summary     *Summary   `json:"summary,omitempty"`
+}
+
+type Gauge struct {
+	DataPoints []*NumberDataPoint `json:"dataPoints"`
+}
+
+type Sum struct {
+	DataPoints             []*NumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                `json:"aggregationTemporality"`
+	IsMonotonic            bool               `json:"isMonotonic"`
+}
+
+type Histogram struct {
+	DataPoints             []*HistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+}
+
+type Summary struct {
+	DataPoints []*SummaryDataPoint `json:"dataPoints"`
+}
+
+// The 64-bit integers are encoded as strings in the JSON encoding of protobuf
+
+type NumberDataPoint struct {
+	Attributes        []*KeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano uint64      `json:"startTimeUnixNano,string,omitempty"`
+	TimeUnixNano      uint64      `json:"timeUnixNano,string"`
+	AsDouble          Double      `json:"asDouble"`
+}
+
+type HistogramDataPoint struct {
+	Attributes        []*KeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano uint64      `json:"startTimeUnixNano,string,omitempty"`
+	TimeUnixNano      uint64      `json:"timeUnixNano,string"`
+	Count             uint64      `json:"count,string"`
+	Sum               Double      `json:"sum"`
+	BucketCounts      []string    `json:"bucketCounts"`
+	ExplicitBounds    []float64   `json:"explicitBounds"`
+}
+
+type SummaryDataPoint struct {
+	Attributes        []*KeyValue        `json:"attributes,omitempty"`
+	StartTimeUnixNano uint64             `json:"startTimeUnixNano,string,omitempty"`
+	TimeUnixNano      uint64             `json:"timeUnixNano,string"`
+	Count             uint64             `json:"count,string"`
+	Sum               Double             `json:"sum"`
+	QuantileValues    []*ValueAtQuantile `json:"quantileValues"`
+}
+
+type ValueAtQuantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    Double  `json:"value"`
+}
@@ -0,0 +1,122 @@
+package remotewrite
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The protobuf encoding of prometheus.WriteRequest,
+// see https://github.com/prometheus/prometheus/blob/main/prompb/remote.proto
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+// Marshal encodes the time series as a WriteRequest
+func Marshal(series []*TimeSeries) []byte {
+	var buf []byte
+	for _, ts := range series {
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, ts.marshal())
+	}
+	return buf
+}
+
+func (ts *TimeSeries) marshal() []byte {
+	var buf []byte
+	for _, label := range ts.Labels {
+		var lb []byte
+		lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+		lb = protowire.AppendString(lb, label.Name)
+		lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+		lb = protowire.AppendString(lb, label.Value)
+
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, lb)
+	}
+	for _, sample := range ts.Samples {
+		var sb []byte
+		sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+		sb = protowire.AppendFixed64(sb, math.Float64bits(sample.Value))
+		sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, uint64(sample.Timestamp))
+
+		buf = protowire.AppendTag(buf, 2, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, sb)
+	}
+	return buf
+}
+
+// FromMetricFamilies converts the gathered metrics to time series,
+// the histograms and summaries are expanded like the text exposition format
+func FromMetricFamilies(families []*dto.MetricFamily, timestamp int64) []*TimeSeries {
+	var series []*TimeSeries
+
+	add := func(name string, labels []*dto.LabelPair, value float64, extra ...Label) {
+		ts := &TimeSeries{Samples: []Sample{{Value: value, Timestamp: timestamp}}}
+		ts.Labels = append(ts.Labels, Label{Name: "__name__", Value: name})
+		for _, label := range labels {
+			ts.Labels = append(ts.Labels, Label{Name: label.GetName(), Value: label.GetValue()})
+		}
+		ts.Labels = append(ts.Labels, extra...)
+		sort.Slice(ts.Labels, func(i, j int) bool { return ts.Labels[i].Name < ts.Labels[j].Name })
+		series = append(series, ts)
+	}
+
+	for _, family := range families {
+		name := family.GetName()
+		for _, m := range family.GetMetric() {
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m.GetLabel(), m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, m.GetLabel(), m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, m.GetLabel(), m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				var hasInf bool
+				for _, b := range h.GetBucket() {
+					hasInf = hasInf || math.IsInf(b.GetUpperBound(), 1)
+					add(name+"_bucket", m.GetLabel(), float64(b.GetCumulativeCount()),
+						Label{Name: "le", Value: formatFloat(b.GetUpperBound())})
+				}
+				if !hasInf {
+					add(name+"_bucket", m.GetLabel(), float64(h.GetSampleCount()), Label{Name: "le", Value: "+Inf"})
+				}
+				add(name+"_sum", m.GetLabel(), h.GetSampleSum())
+				add(name+"_count", m.GetLabel(), float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add(name, m.GetLabel(), q.GetValue(), Label{Name: "quantile", Value: formatFloat(q.GetQuantile())})
+				}
+				add(name+"_sum", m.GetLabel(), s.GetSampleSum())
+				add(name+"_count", m.GetLabel(), float64(s.GetSampleCount()))
+			}
+		}
+	}
+	return series
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
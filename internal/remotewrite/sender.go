@@ -0,0 +1,240 @@
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/logging"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	minBackoff = 500 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+type Options struct {
+	URL         string
+	Username    string
+	Password    string
+	BearerToken string
+	Headers     map[string]string
+	Timeout     time.Duration
+	QueueSize   int
+	MaxRetries  int
+	// ExternalLabels are attached to every series which doesn't have the labels
+	ExternalLabels map[string]string
+	// Transport is the base round tripper of the requests, nil for the default one
+	Transport http.RoundTripper
+}
+
+// Sender pushes the metrics of a prometheus registry with the remote write protocol,
+// the pending requests are kept in a bounded queue and the oldest one is dropped if it's full
+type Sender struct {
+	opts       Options
+	gatherer   prometheus.Gatherer
+	httpClient *http.Client
+	queue      chan []byte
+
+	sent        prometheus.Counter
+	failures    prometheus.Counter
+	dropped     prometheus.Counter
+	queueLength prometheus.Gauge
+
+	logger *slog.Logger
+}
+
+type recoverableError struct {
+	error
+}
+
+func NewSender(opts Options, gatherer prometheus.Gatherer, reg prometheus.Registerer) *Sender {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 10
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+
+	s := &Sender{
+		opts:       opts,
+		gatherer:   gatherer,
+		httpClient: &http.Client{Transport: opts.Transport, Timeout: opts.Timeout},
+		queue:      make(chan []byte, opts.QueueSize),
+		sent: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "metis_sequencer_exporter_remote_write_sent",
+			Help: "Number of remote write requests sent successfully.",
+		}),
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "metis_sequencer_exporter_remote_write_failures",
+			Help: "Number of failed remote write attempts.",
+		}),
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "metis_sequencer_exporter_remote_write_dropped",
+			Help: "Number of remote write requests dropped because the queue is full or retries are exhausted.",
+		}),
+		queueLength: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "metis_sequencer_exporter_remote_write_queue_length",
+			Help: "Number of remote write requests waiting in the queue.",
+		}),
+		logger: logging.New("remote_write"),
+	}
+
+	reg.MustRegister(s.sent, s.failures, s.dropped, s.queueLength)
+	return s
+}
+
+// Run gathers the metrics periodically and sends them until the context is done
+func (s *Sender) Run(basectx context.Context, interval time.Duration) {
+	go s.worker(basectx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-basectx.Done():
+			return
+		case <-ticker.C:
+			payload, err := s.collect(time.Now())
+			if err != nil {
+				s.failures.Inc()
+				s.logger.Error("collect metrics", "err", err)
+				continue
+			}
+			s.enqueue(payload)
+		}
+	}
+}
+
+func (s *Sender) collect(now time.Time) ([]byte, error) {
+	families, err := s.gatherer.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("gather: %w", err)
+	}
+	series := FromMetricFamilies(families, now.UnixMilli())
+	s.addExternalLabels(series)
+	return snappy.Encode(nil, Marshal(series)), nil
+}
+
+// addExternalLabels attaches the external labels to the series, the labels of the series are kept
+// if they have the same names, and the labels are sorted again
+func (s *Sender) addExternalLabels(series []*TimeSeries) {
+	if len(s.opts.ExternalLabels) == 0 {
+		return
+	}
+
+	for _, ts := range series {
+		for name, value := range s.opts.ExternalLabels {
+			if !slices.ContainsFunc(ts.Labels, func(l Label) bool { return l.Name == name }) {
+				ts.Labels = append(ts.Labels, Label{Name: name, Value: value})
+			}
+		}
+		sort.Slice(ts.Labels, func(i, j int) bool { return ts.Labels[i].Name < ts.Labels[j].Name })
+	}
+}
+
+func (s *Sender) enqueue(payload []byte) {
+	for {
+		select {
+		case s.queue <- payload:
+			s.queueLength.Set(float64(len(s.queue)))
+			return
+		default:
+		}
+
+		// drop the oldest one to make room for the latest metrics
+		select {
+		case <-s.queue:
+			s.dropped.Inc()
+			s.logger.Warn("queue is full, drop the oldest request")
+		default:
+		}
+	}
+}
+
+func (s *Sender) worker(basectx context.Context) {
+	for {
+		select {
+		case <-basectx.Done():
+			return
+		case payload := <-s.queue:
+			s.queueLength.Set(float64(len(s.queue)))
+			if err := s.sendWithRetry(basectx, payload); err != nil {
+				s.dropped.Inc()
+				s.logger.Error("drop remote write request", "url", utils.RedactURL(s.opts.URL), "err", err)
+			}
+		}
+	}
+}
+
+func (s *Sender) sendWithRetry(basectx context.Context, payload []byte) error {
+	backoff := minBackoff
+	for attempt := 0; ; attempt++ {
+		err := s.send(basectx, payload)
+		if err == nil {
+			s.sent.Inc()
+			return nil
+		}
+
+		s.failures.Inc()
+		var recoverable recoverableError
+		if !errors.As(err, &recoverable) || attempt >= s.opts.MaxRetries {
+			return err
+		}
+
+		s.logger.Warn("remote write failed, retrying", "url", utils.RedactURL(s.opts.URL), "attempt", attempt+1, "backoff", backoff, "err", err)
+		select {
+		case <-basectx.Done():
+			return basectx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (s *Sender) send(ctx context.Context, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.opts.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "metis-sequencer-exporter")
+	for key, value := range s.opts.Headers {
+		req.Header.Set(key, value)
+	}
+	if s.opts.Username != "" {
+		req.SetBasicAuth(s.opts.Username, s.opts.Password)
+	} else if s.opts.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.opts.BearerToken)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("remote write error: code %d msg %s", resp.StatusCode, bytes.TrimSpace(body))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return recoverableError{err}
+	}
+	return err
+}
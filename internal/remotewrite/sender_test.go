@@ -0,0 +1,201 @@
+package remotewrite
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/transport"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// unmarshal decodes a WriteRequest, it's only used for testing
+func unmarshal(t *testing.T, buf []byte) []*TimeSeries {
+	consume := func(buf []byte) (protowire.Number, protowire.Type, []byte, uint64, []byte) {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			t.Fatalf("invalid tag")
+		}
+		buf = buf[n:]
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(buf)
+			return num, typ, v, 0, buf[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(buf)
+			return num, typ, nil, v, buf[n:]
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(buf)
+			return num, typ, nil, v, buf[n:]
+		}
+		t.Fatalf("unexpected wire type %d", typ)
+		return 0, 0, nil, 0, nil
+	}
+
+	var series []*TimeSeries
+	for len(buf) > 0 {
+		_, _, tsbuf, _, rest := consume(buf)
+		buf = rest
+
+		ts := new(TimeSeries)
+		for len(tsbuf) > 0 {
+			num, _, msg, _, rest := consume(tsbuf)
+			tsbuf = rest
+			switch num {
+			case 1:
+				var label Label
+				for len(msg) > 0 {
+					num, _, value, _, rest := consume(msg)
+					msg = rest
+					if num == 1 {
+						label.Name = string(value)
+					} else {
+						label.Value = string(value)
+					}
+				}
+				ts.Labels = append(ts.Labels, label)
+			case 2:
+				var sample Sample
+				for len(msg) > 0 {
+					num, _, _, value, rest := consume(msg)
+					msg = rest
+					if num == 1 {
+						sample.Value = math.Float64frombits(value)
+					} else {
+						sample.Timestamp = int64(value)
+					}
+				}
+				ts.Samples = append(ts.Samples, sample)
+			}
+		}
+		series = append(series, ts)
+	}
+	return series
+}
+
+var testTime = time.UnixMilli(1700000000000)
+
+func TestSender_send(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_counter", Help: "counter"}, []string{"seq_name"})
+	counter.WithLabelValues("node-0").Add(3)
+	reg.MustRegister(counter)
+
+	var got []*TimeSeries
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if header := r.Header.Get("content-encoding"); header != "snappy" {
+			t.Errorf("expected content-encoding header snappy but got %q", header)
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			t.Errorf("expected basic auth but got %q %q", user, pass)
+		}
+		if header := r.Header.Get("x-scope-orgid"); header != "tenant" {
+			t.Errorf("expected the header of the client config but got %q", header)
+		}
+		compressed, _ := io.ReadAll(r.Body)
+		data, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Errorf("couldn't decode the request %s", err)
+			return
+		}
+		got = unmarshal(t, data)
+	}))
+	defer server.Close()
+
+	base, err := transport.NewBase(&config.HTTPClient{Headers: map[string]string{"X-Scope-OrgID": "tenant"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSender(Options{
+		URL:            server.URL,
+		Username:       "user",
+		Password:       "pass",
+		ExternalLabels: map[string]string{"cluster": "andromeda", "seq_name": "external"},
+		Transport:      base,
+	}, reg, prometheus.NewRegistry())
+	payload, err := s.collect(testTime)
+	if err != nil {
+		t.Fatalf("Sender.collect() error = %v", err)
+	}
+	if err := s.sendWithRetry(context.Background(), payload); err != nil {
+		t.Fatalf("Sender.sendWithRetry() error = %v", err)
+	}
+
+	want := []*TimeSeries{
+		{
+			Labels:  []Label{{"__name__", "test_counter"}, {"cluster", "andromeda"}, {"seq_name", "node-0"}},
+			Samples: []Sample{{Value: 3, Timestamp: testTime.UnixMilli()}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Sender.send() = %v, want %v", got, want)
+	}
+}
+
+func TestSender_sendWithRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		codes        []int
+		maxRetries   int
+		wantErr      bool
+		wantAttempts int32
+	}{
+		{name: "ok", codes: []int{200}, wantAttempts: 1},
+		{name: "retry", codes: []int{500, 429, 200}, maxRetries: 2, wantAttempts: 3},
+		{name: "exhausted", codes: []int{500, 500}, maxRetries: 1, wantErr: true, wantAttempts: 2},
+		{name: "unrecoverable", codes: []int{400, 200}, maxRetries: 3, wantErr: true, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if auth := r.Header.Get("authorization"); auth != "Bearer token" {
+					t.Errorf("expected bearer token but got %q", auth)
+				}
+				w.WriteHeader(tt.codes[attempts.Add(1)-1])
+			}))
+			defer server.Close()
+
+			s := NewSender(Options{URL: server.URL, BearerToken: "token", MaxRetries: tt.maxRetries},
+				prometheus.NewRegistry(), prometheus.NewRegistry())
+			if err := s.sendWithRetry(context.Background(), nil); (err != nil) != tt.wantErr {
+				t.Errorf("Sender.sendWithRetry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("Sender.sendWithRetry() attempts = %v, want %v", got, tt.wantAttempts)
+			}
+
+			wantFailures := float64(tt.wantAttempts - 1)
+			if tt.wantErr {
+				wantFailures++
+			}
+			if got := testutil.ToFloat64(s.failures); got != wantFailures {
+				t.Errorf("Sender.sendWithRetry() failures = %v, want %v", got, wantFailures)
+			}
+		})
+	}
+}
+
+func TestSender_enqueue(t *testing.T) {
+	s := NewSender(Options{QueueSize: 2}, prometheus.NewRegistry(), prometheus.NewRegistry())
+	for _, payload := range []string{"a", "b", "c"} {
+		s.enqueue([]byte(payload))
+	}
+
+	if got := testutil.ToFloat64(s.dropped); got != 1 {
+		t.Errorf("Sender.enqueue() dropped = %v, want 1", got)
+	}
+	if got := string(<-s.queue); got != "b" {
+		t.Errorf("Sender.enqueue() oldest = %v, want b", got)
+	}
+}
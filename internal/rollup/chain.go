@@ -0,0 +1,84 @@
+package rollup
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// chainABI is the common view functions of CanonicalTransactionChain and StateCommitmentChain,
+// the ByChainId variants are used by the multi-chain contracts of Metis
+const chainABI = `[
+	{"type":"function","name":"getTotalElements","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"getTotalBatches","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"getTotalElementsByChainId","stateMutability":"view","inputs":[{"name":"_chainId","type":"uint256"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"getTotalBatchesByChainId","stateMutability":"view","inputs":[{"name":"_chainId","type":"uint256"}],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+var parsedABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(chainABI))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// Chain reads the rollup chain contracts on L1
+type Chain struct {
+	caller  ethereum.ContractCaller
+	address common.Address
+	chainID *big.Int
+}
+
+// NewChain returns a contract reader, the ByChainId functions are used if the chainID is not zero
+func NewChain(caller ethereum.ContractCaller, address common.Address, chainID uint64) *Chain {
+	c := &Chain{caller: caller, address: address}
+	if chainID != 0 {
+		c.chainID = new(big.Int).SetUint64(chainID)
+	}
+	return c
+}
+
+// TotalElements returns the number of the committed elements at the block, nil for the latest
+func (c *Chain) TotalElements(ctx context.Context, block *big.Int) (uint64, error) {
+	if c.chainID != nil {
+		return c.call(ctx, block, "getTotalElementsByChainId", c.chainID)
+	}
+	return c.call(ctx, block, "getTotalElements")
+}
+
+// TotalBatches returns the number of the committed batches at the block, nil for the latest
+func (c *Chain) TotalBatches(ctx context.Context, block *big.Int) (uint64, error) {
+	if c.chainID != nil {
+		return c.call(ctx, block, "getTotalBatchesByChainId", c.chainID)
+	}
+	return c.call(ctx, block, "getTotalBatches")
+}
+
+func (c *Chain) call(ctx context.Context, block *big.Int, method string, args ...any) (uint64, error) {
+	input, err := parsedABI.Pack(method, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", method, err)
+	}
+
+	output, err := c.caller.CallContract(ctx, ethereum.CallMsg{To: &c.address, Data: input}, block)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", method, err)
+	}
+
+	res, err := parsedABI.Unpack(method, output)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", method, err)
+	}
+
+	value, ok := res[0].(*big.Int)
+	if !ok || !value.IsUint64() {
+		return 0, fmt.Errorf("%s: invalid result %v", method, res[0])
+	}
+	return value.Uint64(), nil
+}
@@ -0,0 +1,75 @@
+package rollup
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+type mockCaller struct {
+	t       *testing.T
+	address common.Address
+	input   []byte
+	output  *big.Int
+}
+
+func (m *mockCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if *call.To != m.address {
+		m.t.Errorf("expected contract %s got %s", m.address, call.To)
+	}
+	if !bytes.Equal(call.Data, m.input) {
+		m.t.Errorf("expected input %x got %x", m.input, call.Data)
+	}
+	return math.U256Bytes(new(big.Int).Set(m.output)), nil
+}
+
+func TestChain_TotalElements(t *testing.T) {
+	address := common.HexToAddress("0x56a76bcC92361f6DF8D75476feD8843EdC70e1C9")
+
+	tests := []struct {
+		name    string
+		chainID uint64
+		input   []byte
+		output  *big.Int
+		want    uint64
+		wantErr bool
+	}{
+		{
+			name:   "default",
+			input:  common.FromHex("0x7aa63a86"),
+			output: big.NewInt(100),
+			want:   100,
+		},
+		{
+			name:    "by-chain-id",
+			chainID: 1088,
+			input:   append(common.FromHex("0x8a52e622"), math.U256Bytes(big.NewInt(1088))...),
+			output:  big.NewInt(200),
+			want:    200,
+		},
+		{
+			name:    "overflow",
+			input:   common.FromHex("0x7aa63a86"),
+			output:  new(big.Int).Lsh(big.NewInt(1), 64),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caller := &mockCaller{t: t, address: address, input: tt.input, output: tt.output}
+			got, err := NewChain(caller, address, tt.chainID).TotalElements(context.Background(), nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Chain.TotalElements() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Chain.TotalElements() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
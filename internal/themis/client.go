@@ -4,17 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 )
 
+// ErrNotFound is returned if the rest server replies 404 to the query
+var ErrNotFound = errors.New("not found")
+
 type Client struct {
 	restHost   string
 	httpClient *http.Client
 }
 
-func NewClient(rest string) (*Client, error) {
+// NewClient returns a client of the rest server, nil httpClient for the default one
+func NewClient(rest string, httpClient *http.Client) (*Client, error) {
 	parsed, err := url.Parse(rest)
 	if err != nil {
 		return nil, fmt.Errorf("invalid rest server base url: %s", rest)
@@ -22,9 +27,12 @@ func NewClient(rest string) (*Client, error) {
 	if parsed.Path != "/" {
 		parsed.Path = ""
 	}
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
 	return &Client{
 		restHost:   parsed.String(),
-		httpClient: &http.Client{},
+		httpClient: httpClient,
 	}, nil
 }
 
@@ -67,9 +75,15 @@ func (c *Client) Get(ctx context.Context, path string, result any) (int64, error
 
 	var data ErrorResponse
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		if resp.StatusCode == http.StatusNotFound {
+			return 0, fmt.Errorf("rest client error: path %s: %w", path, ErrNotFound)
+		}
 		return 0, err
 	}
 
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, fmt.Errorf("rest client error: path %s code %d msg %s: %w", path, data.Code, data.Error, ErrNotFound)
+	}
 	return 0, fmt.Errorf("rest client error: path %s code %d msg %s", path, data.Code, data.Error)
 }
 
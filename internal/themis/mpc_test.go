@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -31,6 +32,9 @@ func TestClient_LatestMpcInfo(t *testing.T) {
 		args     MpcAddrType
 		want     *MpcInfoResponse
 		wantErr  bool
+		// status is the status code of the error response, 400 by default
+		status       int
+		wantNotFound bool
 	}{
 		{
 			name:     "ok",
@@ -61,6 +65,13 @@ func TestClient_LatestMpcInfo(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:         "not-found",
+			args:         BlobSubmitMpcAddr,
+			wantErr:      true,
+			status:       http.StatusNotFound,
+			wantNotFound: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -77,7 +88,11 @@ func TestClient_LatestMpcInfo(t *testing.T) {
 				}
 
 				if tt.wantErr {
-					w.WriteHeader(http.StatusBadRequest)
+					status := http.StatusBadRequest
+					if tt.status != 0 {
+						status = tt.status
+					}
+					w.WriteHeader(status)
 					w.Header().Add("content-type", "application/json")
 					_ = json.NewEncoder(w).Encode(&ErrorResponse{Error: "error"})
 				} else {
@@ -102,6 +117,9 @@ func TestClient_LatestMpcInfo(t *testing.T) {
 				t.Errorf("Client.LatestMpcInfo() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if errors.Is(err, ErrNotFound) != tt.wantNotFound {
+				t.Errorf("Client.LatestMpcInfo() error = %v, wantNotFound %v", err, tt.wantNotFound)
+			}
 			if err == nil && !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Client.LatestMpcInfo() = %v, want %v", got, tt.want)
 			}
@@ -0,0 +1,83 @@
+package transport
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// the circuit breaker states, the values are exported by the state metric
+const (
+	StateClosed   = 0
+	StateOpen     = 1
+	StateHalfOpen = 2
+)
+
+// ErrCircuitOpen is returned without calling the endpoint while the breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// breaker opens after the consecutive failures reach the threshold and rejects the calls,
+// after the open timeout a single trial call is allowed and its result closes or reopens the breaker
+type breaker struct {
+	threshold   int
+	openTimeout time.Duration
+	onChange    func(state int)
+
+	mutex    sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+	trying   bool
+}
+
+// allow reports whether a call can be made now
+func (b *breaker) allow(now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if now.Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.setState(StateHalfOpen)
+		b.trying = true
+		return true
+	case StateHalfOpen:
+		if b.trying {
+			return false
+		}
+		b.trying = true
+		return true
+	}
+	return true
+}
+
+// record updates the breaker with the result of an allowed call
+func (b *breaker) record(now time.Time, success bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.trying = false
+	if success {
+		b.failures = 0
+		b.setState(StateClosed)
+		return
+	}
+
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.openedAt = now
+		b.setState(StateOpen)
+	}
+}
+
+func (b *breaker) setState(state int) {
+	if b.state == state {
+		return
+	}
+	b.state = state
+	if b.onChange != nil {
+		b.onChange(state)
+	}
+}
@@ -0,0 +1,136 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+)
+
+// TLSConfig loads the CA bundle and the client certificate of the config
+func TLSConfig(conf *config.TLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         conf.ServerName,
+		InsecureSkipVerify: conf.InsecureSkipVerify, //nolint:gosec
+	}
+
+	if conf.CAFile != "" {
+		data, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate found in ca file %s", conf.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if conf.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Header returns the auth and the custom headers of the config
+func Header(conf *config.HTTPClient) http.Header {
+	header := make(http.Header)
+	if conf == nil {
+		return header
+	}
+
+	if ba := conf.BasicAuth; ba != nil {
+		auth := base64.StdEncoding.EncodeToString([]byte(ba.Username + ":" + ba.Password))
+		header.Set("Authorization", "Basic "+auth)
+	}
+	if conf.BearerToken != "" {
+		header.Set("Authorization", "Bearer "+conf.BearerToken)
+	}
+	for key, value := range conf.Headers {
+		header.Set(key, value)
+	}
+	return header
+}
+
+// NewBase returns the round tripper with the tls settings and the headers of the config,
+// nil config for the default transport
+func NewBase(conf *config.HTTPClient) (http.RoundTripper, error) {
+	if conf == nil {
+		return http.DefaultTransport, nil
+	}
+
+	var base http.RoundTripper = http.DefaultTransport
+	if conf.TLS != nil {
+		tlsConfig, err := TLSConfig(conf.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		base = transport
+	}
+
+	if header := Header(conf); len(header) > 0 {
+		base = &headerTransport{base: base, header: header}
+	}
+	return base, nil
+}
+
+// NewHTTPClient returns a client with the settings of the config but without retries
+func NewHTTPClient(conf *config.HTTPClient) (*http.Client, error) {
+	base, err := NewBase(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: base}, nil
+}
+
+// RPCOptions returns the json-rpc dial options of the config, the http client is used by the http endpoints,
+// and the websocket endpoints are dialed with the same tls settings and headers
+func RPCOptions(httpClient *http.Client, conf *config.HTTPClient) ([]rpc.ClientOption, error) {
+	opts := []rpc.ClientOption{rpc.WithHTTPClient(httpClient)}
+	if conf == nil {
+		return opts, nil
+	}
+
+	if header := Header(conf); len(header) > 0 {
+		opts = append(opts, rpc.WithHeaders(header))
+	}
+	if conf.TLS != nil {
+		tlsConfig, err := TLSConfig(conf.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, rpc.WithWebsocketDialer(websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment,
+			HandshakeTimeout: 45 * time.Second,
+			TLSClientConfig:  tlsConfig,
+		}))
+	}
+	return opts, nil
+}
+
+// headerTransport sets the headers on every request
+type headerTransport struct {
+	base   http.RoundTripper
+	header http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for key, values := range t.header {
+		req.Header[key] = values
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,103 @@
+package transport
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Authorization", r.Header.Get("Authorization"))
+		w.Header().Set("X-Custom", r.Header.Get("X-Custom"))
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		conf       *config.HTTPClient
+		wantAuth   string
+		wantCustom string
+		wantErr    bool
+	}{
+		{
+			name:    "unknown-ca",
+			conf:    nil,
+			wantErr: true,
+		},
+		{
+			name:     "ca-basic-auth",
+			conf:     &config.HTTPClient{TLS: &config.TLS{CAFile: caFile}, BasicAuth: &config.BasicAuth{Username: "user", Password: "pass"}},
+			wantAuth: "Basic dXNlcjpwYXNz",
+		},
+		{
+			name: "insecure-bearer-headers",
+			conf: &config.HTTPClient{
+				TLS:         &config.TLS{InsecureSkipVerify: true},
+				BearerToken: "token",
+				Headers:     map[string]string{"X-Custom": "value"},
+			},
+			wantAuth:   "Bearer token",
+			wantCustom: "value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewHTTPClient(tt.conf)
+			if err != nil {
+				t.Fatalf("NewHTTPClient() error = %v", err)
+			}
+
+			resp, err := client.Get(server.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			resp.Body.Close() //nolint:errcheck
+
+			if got := resp.Header.Get("X-Authorization"); got != tt.wantAuth {
+				t.Errorf("Authorization = %q, want %q", got, tt.wantAuth)
+			}
+			if got := resp.Header.Get("X-Custom"); got != tt.wantCustom {
+				t.Errorf("X-Custom = %q, want %q", got, tt.wantCustom)
+			}
+		})
+	}
+}
+
+func TestTLSConfig_Errors(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.pem")
+	if err := os.WriteFile(invalid, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		conf *config.TLS
+	}{
+		{"missing-ca", &config.TLS{CAFile: filepath.Join(dir, "missing.pem")}},
+		{"invalid-ca", &config.TLS{CAFile: invalid}},
+		{"invalid-cert", &config.TLS{CertFile: invalid, KeyFile: invalid}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := TLSConfig(tt.conf); err == nil {
+				t.Errorf("TLSConfig() error = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Options is the retry and circuit breaker settings of the endpoints
+type Options struct {
+	// the retries after the first attempt of a request
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// the consecutive failed requests to open the circuit breaker
+	FailureThreshold int
+	// how long the breaker stays open before a trial request
+	OpenTimeout time.Duration
+}
+
+var DefaultOptions = Options{
+	MaxRetries:       2,
+	MinBackoff:       200 * time.Millisecond,
+	MaxBackoff:       2 * time.Second,
+	FailureThreshold: 5,
+	OpenTimeout:      30 * time.Second,
+}
+
+// Pool shares the circuit breaker between the clients of the same endpoint
+type Pool struct {
+	opts Options
+
+	state   *prometheus.GaugeVec
+	retries *prometheus.CounterVec
+
+	mutex    sync.Mutex
+	breakers map[string]*breaker
+	// labels are the metric labels of the urls, and hosts counts the urls of every host,
+	// so the urls of the same host are labeled by the order they're added in
+	labels map[string]string
+	hosts  map[string]int
+}
+
+func NewPool(reg prometheus.Registerer, opts Options) *Pool {
+	p := &Pool{
+		opts: opts,
+		state: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "metis_sequencer_exporter_circuit_breaker_state",
+			Help: "Circuit breaker state of the endpoint, 0 closed, 1 open and 2 half-open.",
+		}, []string{"endpoint"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "metis_sequencer_exporter_retries",
+			Help: "Number of retried requests to the endpoint.",
+		}, []string{"endpoint"}),
+		breakers: make(map[string]*breaker),
+		labels:   make(map[string]string),
+		hosts:    make(map[string]int),
+	}
+	reg.MustRegister(p.state, p.retries)
+	return p
+}
+
+// Transport returns the transport of the endpoint url which wraps the base one
+func (p *Pool) Transport(endpoint string, base http.RoundTripper) *Transport {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	label := prometheus.Labels{"endpoint": p.label(endpoint)}
+	b, ok := p.breakers[endpoint]
+	if !ok {
+		state := p.state.With(label)
+		state.Set(StateClosed)
+		b = &breaker{
+			threshold:   p.opts.FailureThreshold,
+			openTimeout: p.opts.OpenTimeout,
+			onChange:    func(s int) { state.Set(float64(s)) },
+		}
+		p.breakers[endpoint] = b
+	}
+
+	return &Transport{
+		base:    base,
+		opts:    p.opts,
+		retries: p.retries.With(label),
+		breaker: b,
+	}
+}
+
+// Label returns the endpoint label of the url, which is the same in the metrics of every client of the url
+func (p *Pool) Label(endpoint string) string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.label(endpoint)
+}
+
+// label should be called with the mutex held
+func (p *Pool) label(endpoint string) string {
+	if _, ok := p.labels[endpoint]; !ok {
+		host := utils.URLHost(endpoint)
+		p.labels[endpoint] = utils.EndpointLabel(endpoint, p.hosts[host])
+		p.hosts[host]++
+	}
+	return p.labels[endpoint]
+}
+
+// HTTPClient returns a client of the endpoint url with the tls and the auth settings of the config
+func (p *Pool) HTTPClient(endpoint string, conf *config.HTTPClient) (*http.Client, error) {
+	base, err := NewBase(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: p.Transport(endpoint, base)}, nil
+}
+
+// RPCOptions returns the json-rpc dial options of the endpoint url with the settings of the config,
+// only the http endpoints go through the retries and the circuit breaker, the websocket endpoints
+// keep a single connection which is dialed with the tls settings and the headers only
+func (p *Pool) RPCOptions(endpoint string, conf *config.HTTPClient) ([]rpc.ClientOption, error) {
+	client, err := p.HTTPClient(endpoint, conf)
+	if err != nil {
+		return nil, err
+	}
+	return RPCOptions(client, conf)
+}
+
+// Transport retries the transient errors with jittered exponential backoff,
+// and rejects the requests while the circuit breaker of the endpoint is open
+type Transport struct {
+	base    http.RoundTripper
+	opts    Options
+	retries prometheus.Counter
+	breaker *breaker
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.breaker.allow(time.Now()) {
+		return nil, ErrCircuitOpen
+	}
+
+	resp, err := t.roundTrip(req)
+	t.breaker.record(time.Now(), err == nil && !isTransient(resp.StatusCode))
+	return resp, err
+}
+
+func (t *Transport) roundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if err == nil && !isTransient(resp.StatusCode) {
+			return resp, nil
+		}
+		// the cancelled requests and the errors which fail again, e.g. the bad certificates
+		// and the invalid urls, are returned without retries
+		if err != nil && (req.Context().Err() != nil || !isTemporary(err)) {
+			return nil, err
+		}
+		if attempt >= t.opts.MaxRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+
+		// the request is retried, so the body of the failed response is dropped
+		if err == nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close() //nolint:errcheck
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(t.backoff(attempt)):
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("rewind request body: %s", err)
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		t.retries.Inc()
+	}
+}
+
+// backoff returns the jittered delay before the retry, between the half and the full exponential backoff
+func (t *Transport) backoff(attempt int) time.Duration {
+	backoff := t.opts.MaxBackoff
+	if attempt < 32 {
+		backoff = min(t.opts.MinBackoff<<attempt, t.opts.MaxBackoff)
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// isTransient reports whether the status code is worth to retry, they're the rate limits and the server errors
+func isTransient(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// isTemporary reports whether the transport error is worth to retry,
+// they're the timeouts and the connections which are refused or reset by the server
+func isTemporary(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
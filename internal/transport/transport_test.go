@@ -0,0 +1,285 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTransport_Retry(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int32
+		status       int
+		wantStatus   int
+		wantRequests int32
+		wantRetries  float64
+	}{
+		{
+			name:         "ok",
+			wantStatus:   http.StatusOK,
+			wantRequests: 1,
+		},
+		{
+			name:         "recovered",
+			failures:     2,
+			status:       http.StatusServiceUnavailable,
+			wantStatus:   http.StatusOK,
+			wantRequests: 3,
+			wantRetries:  2,
+		},
+		{
+			name:         "exhausted",
+			failures:     5,
+			status:       http.StatusBadGateway,
+			wantStatus:   http.StatusBadGateway,
+			wantRequests: 3,
+			wantRetries:  2,
+		},
+		{
+			name:         "server-error",
+			failures:     1,
+			status:       http.StatusInternalServerError,
+			wantStatus:   http.StatusOK,
+			wantRequests: 2,
+			wantRetries:  1,
+		},
+		{
+			name:         "not-transient",
+			failures:     1,
+			status:       http.StatusNotFound,
+			wantStatus:   http.StatusNotFound,
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if body := make([]byte, 4); r.Method == http.MethodPost {
+					if n, _ := r.Body.Read(body); string(body[:n]) != "ping" {
+						t.Errorf("unexpected body %q", body[:n])
+					}
+				}
+				if requests.Add(1) <= tt.failures {
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			opts := DefaultOptions
+			opts.MinBackoff, opts.MaxBackoff = time.Millisecond, time.Millisecond
+			pool := NewPool(prometheus.NewRegistry(), opts)
+			client := &http.Client{Transport: pool.Transport(server.URL, http.DefaultTransport)}
+
+			resp, err := client.Post(server.URL, "text/plain", strings.NewReader("ping"))
+			if err != nil {
+				t.Fatalf("Post() error = %v", err)
+			}
+			resp.Body.Close() //nolint:errcheck
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Post() status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+			if got := testutil.ToFloat64(pool.retries); got != tt.wantRetries {
+				t.Errorf("retries = %v, want %v", got, tt.wantRetries)
+			}
+		})
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransport_RetryErrors(t *testing.T) {
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+
+	tests := []struct {
+		name string
+		url  string
+		// base replies the error, the default transport is used if it's nil
+		base         func(req *http.Request, cancel context.CancelFunc) error
+		wantRequests int32
+	}{
+		{
+			name: "timeout",
+			base: func(*http.Request, context.CancelFunc) error {
+				return &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}
+			},
+			wantRequests: 3,
+		},
+		{
+			name: "reset",
+			base: func(*http.Request, context.CancelFunc) error {
+				return &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+			},
+			wantRequests: 3,
+		},
+		{
+			name: "refused",
+			base: func(*http.Request, context.CancelFunc) error {
+				return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+			},
+			wantRequests: 3,
+		},
+		{
+			name: "cancelled",
+			base: func(req *http.Request, cancel context.CancelFunc) error {
+				cancel()
+				return req.Context().Err()
+			},
+			wantRequests: 1,
+		},
+		{
+			name:         "certificate",
+			url:          tlsServer.URL,
+			wantRequests: 1,
+		},
+		{
+			name:         "invalid-url",
+			url:          "ftp://127.0.0.1",
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var requests atomic.Int32
+			base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				requests.Add(1)
+				if tt.base == nil {
+					return http.DefaultTransport.RoundTrip(req)
+				}
+				return nil, tt.base(req, cancel)
+			})
+
+			url := tt.url
+			if url == "" {
+				url = "http://127.0.0.1"
+			}
+
+			opts := DefaultOptions
+			opts.MinBackoff, opts.MaxBackoff = time.Millisecond, time.Millisecond
+			pool := NewPool(prometheus.NewRegistry(), opts)
+			client := &http.Client{Transport: pool.Transport(url, base)}
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp, err := client.Do(req); err == nil {
+				resp.Body.Close() //nolint:errcheck
+				t.Fatalf("Do() expected error")
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestTransport_Breaker(t *testing.T) {
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	opts := Options{FailureThreshold: 2, OpenTimeout: 50 * time.Millisecond}
+	pool := NewPool(prometheus.NewRegistry(), opts)
+	client := &http.Client{Transport: pool.Transport(server.URL, http.DefaultTransport)}
+	state := func() float64 { return testutil.ToFloat64(pool.state) }
+
+	get := func() error {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	for i := 0; i < opts.FailureThreshold; i++ {
+		if err := get(); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+	}
+	if got := state(); got != StateOpen {
+		t.Fatalf("state = %v, want open", got)
+	}
+	if err := get(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Get() error = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	// the trial request fails and reopens the breaker
+	time.Sleep(opts.OpenTimeout)
+	if err := get(); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got := state(); got != StateOpen {
+		t.Fatalf("state = %v, want open", got)
+	}
+
+	// the trial request succeeds and closes the breaker
+	healthy.Store(true)
+	time.Sleep(opts.OpenTimeout)
+	if err := get(); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got := state(); got != StateClosed {
+		t.Fatalf("state = %v, want closed", got)
+	}
+}
+
+func TestPool_Labels(t *testing.T) {
+	pool := NewPool(prometheus.NewRegistry(), DefaultOptions)
+	// the urls differ only in the api keys, and the same url shares the breaker
+	for _, endpoint := range []string{
+		"https://mainnet.infura.io/v3/0123456789abcdef0123456789abcdef",
+		"https://mainnet.infura.io/v3/fedcba9876543210fedcba9876543210",
+		"https://mainnet.infura.io/v3/0123456789abcdef0123456789abcdef",
+		"http://localhost:8545",
+	} {
+		pool.Transport(endpoint, http.DefaultTransport)
+	}
+
+	for _, label := range []string{"mainnet.infura.io#0", "mainnet.infura.io#1", "localhost:8545#0"} {
+		if got := testutil.ToFloat64(pool.state.WithLabelValues(label)); got != StateClosed {
+			t.Errorf("state of %s = %v, want closed", label, got)
+		}
+	}
+	if got := testutil.CollectAndCount(pool.state); got != 3 {
+		t.Errorf("state series = %d, want 3", got)
+	}
+
+	// the rpc metrics share the labels of the breakers
+	if got := pool.Label("https://mainnet.infura.io/v3/fedcba9876543210fedcba9876543210"); got != "mainnet.infura.io#1" {
+		t.Errorf("Pool.Label() = %s, want mainnet.infura.io#1", got)
+	}
+	if got := pool.Label("http://localhost:8546"); got != "localhost:8546#0" {
+		t.Errorf("Pool.Label() of a new url = %s, want localhost:8546#0", got)
+	}
+}
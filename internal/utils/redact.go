@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const redacted = "xxxxx"
+
+// minSecretSegment is the min length of the path segments which are regarded as api keys,
+// the rpc providers like infura and alchemy put the keys in the url path
+const minSecretSegment = 16
+
+var urlRegexp = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// RedactURL hides the user info, the query values and the long path segments of the url
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	if u.User != nil {
+		u.User = url.User(redacted)
+	}
+
+	if u.Path != "" {
+		segments := strings.Split(u.Path, "/")
+		for i, segment := range segments {
+			if len(segment) >= minSecretSegment {
+				segments[i] = redacted
+			}
+		}
+		u.Path = strings.Join(segments, "/")
+		u.RawPath = ""
+	}
+
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query[key] = []string{redacted}
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	return u.String()
+}
+
+// URLHost returns the host and the port of the url without the user info, the path and the query
+func URLHost(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "unknown"
+	}
+	return u.Host
+}
+
+// EndpointLabel returns the metric label of the url, the urls which differ only in the secrets
+// have the same redacted form, so they're told apart by the index instead, e.g. host:port#1
+func EndpointLabel(raw string, index int) string {
+	return URLHost(raw) + "#" + strconv.Itoa(index)
+}
+
+// RedactURLs redacts every url in the text
+func RedactURLs(text string) string {
+	return urlRegexp.ReplaceAllStringFunc(text, RedactURL)
+}
+
+// RedactAttr is a slog ReplaceAttr function which redacts the urls in the string and error values
+func RedactAttr(_ []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		if value := a.Value.String(); strings.Contains(value, "://") {
+			a.Value = slog.StringValue(RedactURLs(value))
+		}
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok && strings.Contains(err.Error(), "://") {
+			a.Value = slog.StringValue(RedactURLs(err.Error()))
+		}
+	}
+	return a
+}
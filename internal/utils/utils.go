@@ -2,19 +2,16 @@ package utils
 
 import (
 	"encoding/json"
+	"maps"
 	"math/big"
+	"slices"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/shopspring/decimal"
 )
 
 func IsZeroAddress(addr common.Address) bool {
-	for i := range addr {
-		if i != 0 {
-			return false
-		}
-	}
-	return true
+	return addr == common.Address{}
 }
 
 func ToEther(value *big.Int) float64 {
@@ -37,3 +34,8 @@ func JsonString(value any) string {
 	val, _ := json.Marshal(value)
 	return string(val)
 }
+
+// SortedKeys returns the keys of the map in the ascending order, so the maps are iterated deterministically
+func SortedKeys[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestIsZeroAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		addr common.Address
+		want bool
+	}{
+		{name: "zero", addr: common.Address{}, want: true},
+		{name: "first-byte", addr: common.HexToAddress("0x0100000000000000000000000000000000000000"), want: false},
+		{name: "last-byte", addr: common.HexToAddress("0x01"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsZeroAddress(tt.addr); got != tt.want {
+				t.Errorf("IsZeroAddress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
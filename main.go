@@ -11,30 +11,71 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/metis-devops/metis-sequencer-exporter/internal/alert"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/collector"
 	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/logging"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/otlp"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/remotewrite"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/transport"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/utils"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{ReplaceAttr: utils.RedactAttr})))
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "rules":
+			os.Exit(rulesCommand(os.Args[2:]))
+		case "check":
+			os.Exit(checkCommand(os.Args[2:]))
+		case "validate":
+			os.Exit(validateCommand(os.Args[2:]))
+		}
+	}
+
 	var (
 		ConfPath string
 		Port     uint64
 
 		SequencerScrapeInterval time.Duration
 		WalletScrapeInterval    time.Duration
+		WalletBurnRateWindow    time.Duration
+
+		LogLevel  slog.Level
+		LogFormat string
+
+		ProbeEnabled bool
+
+		OnDemand            bool
+		OnDemandMinAge      time.Duration
+		OnDemandConcurrency int
 	)
 
 	flag.DurationVar(&SequencerScrapeInterval, "interval.sequencer", time.Second*15, "scrape interval")
 	flag.DurationVar(&WalletScrapeInterval, "interval.wallet", time.Minute, "scrape interval")
+	flag.DurationVar(&WalletBurnRateWindow, "wallet.burnrate-window", time.Hour*6, "the rolling window of wallet spend rate")
 	flag.StringVar(&ConfPath, "config", "config.yaml", "config path")
 	flag.Uint64Var(&Port, "port", 9090, "the listening port")
+	flag.TextVar(&LogLevel, "log.level", slog.LevelInfo, "the log level, debug, info, warn or error")
+	flag.StringVar(&LogFormat, "log.format", logging.FormatText, "the log format, text or json")
+	flag.BoolVar(&ProbeEnabled, "probe.enabled", false, "serve the /probe endpoint which scrapes the target of the request")
+	flag.BoolVar(&OnDemand, "collect.on-demand", false, "scrape the targets on every metrics request instead of polling them in the background")
+	flag.DurationVar(&OnDemandMinAge, "collect.min-age", time.Second*10, "the min age of the results to scrape the targets again in the on-demand mode")
+	flag.IntVar(&OnDemandConcurrency, "collect.concurrency", 16, "the max targets to scrape at the same time in the on-demand mode")
 	flag.Parse()
 
 	if Port > 65535 {
 		slog.Error("invalid port", "port", Port)
 		return
 	}
+	if SequencerScrapeInterval <= 0 || WalletScrapeInterval <= 0 {
+		slog.Error("scrape interval is not positive", "sequencer", SequencerScrapeInterval, "wallet", WalletScrapeInterval)
+		os.Exit(1)
+	}
 
 	conf, err := config.Parse(ConfPath)
 	if err != nil {
@@ -42,37 +83,126 @@ func main() {
 		os.Exit(1)
 	}
 
+	var moduleLevels map[string]slog.Level
+	if conf.Log != nil {
+		moduleLevels = conf.Log.Levels
+	}
+	if err := logging.Setup(LogLevel, LogFormat, moduleLevels); err != nil {
+		slog.Error("logging", "err", err)
+		os.Exit(1)
+	}
+
 	basectx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
 	reg := prometheus.NewRegistry()
 
-	seqMetric, err := NewSeqMetric(basectx, reg, conf)
-	if err != nil {
-		slog.Error("NewSeqMetrics", "err", err)
-		os.Exit(1)
+	// the metrics are gathered by the on-demand gatherer after the targets are scraped
+	var ondemand *collector.OnDemand
+	var basereg prometheus.Registerer = reg
+	if OnDemand {
+		slog.Info("scrape the targets on demand", "min_age", OnDemandMinAge, "concurrency", OnDemandConcurrency)
+		ondemand = collector.NewOnDemand(basectx, OnDemandMinAge, OnDemandConcurrency)
+		basereg = ondemand
 	}
 
-	walletMetric, err := NewWalletMetric(basectx, reg, conf)
-	if err != nil {
-		slog.Error("NewBalanceMetric", "err", err)
-		os.Exit(1)
+	for network, netconf := range conf.Networks {
+		netreg := prometheus.WrapRegistererWith(prometheus.Labels{"network": network}, basereg)
+
+		pool := transport.NewPool(netreg, transport.DefaultOptions)
+
+		seqMetric, err := NewSeqMetric(netreg, network, netconf, pool)
+		if err != nil {
+			slog.Error("NewSeqMetrics", "network", network, "err", err)
+			os.Exit(1)
+		}
+
+		walletMetric, err := NewWalletMetric(netreg, network, netconf, pool, WalletBurnRateWindow)
+		if err != nil {
+			slog.Error("NewBalanceMetric", "network", network, "err", err)
+			os.Exit(1)
+		}
+
+		scheduler := collector.NewScheduler(netreg, logging.New("collector").With("network", network))
+		probes := append(seqMetric.Probes(SequencerScrapeInterval), walletMetric.Probes(WalletScrapeInterval)...)
+
+		go walletMetric.Run(basectx)
+		if ondemand != nil {
+			ondemand.Add(scheduler, probes...)
+		} else {
+			go scheduler.Run(basectx, probes...)
+		}
 	}
 
-	scrapeFailuresMetric := prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "metis_sequencer_exporter_failures",
-			Help: "Number of scrape errors.",
-		},
-		[]string{"svc_name"},
-	)
-	reg.MustRegister(scrapeFailuresMetric)
+	// the /metrics requests scrape the targets on demand, while the background senders read the last results,
+	// so only the scrapes of prometheus set the freshness
+	var background prometheus.Gatherer = reg
+	if ondemand != nil {
+		background = prometheus.Gatherers{reg, ondemand.Cached()}
+	}
+
+	if conf.OTLP != nil {
+		interval := time.Duration(conf.OTLP.Interval)
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		slog.Info("push metrics to otlp collector", "endpoint", utils.RedactURL(conf.OTLP.Endpoint), "interval", interval)
+		httpClient, err := transport.NewHTTPClient(conf.OTLP.Client)
+		if err != nil {
+			slog.Error("NewOTLPClient", "err", err)
+			os.Exit(1)
+		}
+		pusher := otlp.NewPusher(conf.OTLP.Endpoint, conf.OTLP.Headers, conf.OTLP.ResourceAttributes, background, httpClient)
+		go pusher.Run(basectx, interval)
+	}
 
-	go seqMetric.Scrape(basectx, scrapeFailuresMetric, SequencerScrapeInterval)
-	go walletMetric.Scrape(basectx, scrapeFailuresMetric, WalletScrapeInterval)
+	if rw := conf.RemoteWrite; rw != nil {
+		interval := time.Duration(rw.Interval)
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		base, err := transport.NewBase(rw.Client)
+		if err != nil {
+			slog.Error("NewRemoteWriteTransport", "err", err)
+			os.Exit(1)
+		}
+		opts := remotewrite.Options{
+			URL:            rw.URL,
+			BearerToken:    rw.BearerToken,
+			Headers:        rw.Headers,
+			Timeout:        time.Duration(rw.Timeout),
+			QueueSize:      rw.QueueSize,
+			MaxRetries:     rw.MaxRetries,
+			ExternalLabels: rw.ExternalLabels,
+			Transport:      base,
+		}
+		if rw.BasicAuth != nil {
+			opts.Username, opts.Password = rw.BasicAuth.Username, rw.BasicAuth.Password
+		}
+		slog.Info("push metrics with remote write", "url", utils.RedactURL(rw.URL), "interval", interval)
+		sender := remotewrite.NewSender(opts, background, reg)
+		go sender.Run(basectx, interval)
+	}
+
+	if conf.Alerting != nil {
+		engine, err := alert.NewEngine(conf.Alerting, background)
+		if err != nil {
+			slog.Error("NewAlertEngine", "err", err)
+			os.Exit(1)
+		}
+		slog.Info("alert evaluator is enabled", "rules", len(conf.Alerting.Rules), "receivers", len(conf.Alerting.Receivers))
+		go engine.Run(basectx)
+	}
 
 	server := &http.Server{Addr: fmt.Sprintf(":%d", Port)}
-	http.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg}))
+	metricsHandler := promhttp.HandlerFor(background, promhttp.HandlerOpts{Registry: reg})
+	if ondemand != nil {
+		metricsHandler = ondemand.Handler(metricsHandler)
+	}
+	http.Handle("/metrics", metricsHandler)
+	if ProbeEnabled {
+		http.Handle("/probe", &probeHandler{logger: logging.New("probe")})
+	}
 	http.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) { fmt.Fprintln(w, "pong") })
 
 	go func() {
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/dtl"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/ethrpc"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/themis"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// the modules of the /probe endpoint
+var probeModules = []string{"l2geth", "themis", "l1dtl", "wallet"}
+
+const (
+	// defaultProbeTimeout is used if prometheus doesn't send its scrape timeout
+	defaultProbeTimeout = 10 * time.Second
+	// probeTimeoutOffset leaves time for prometheus to receive the response before its timeout
+	probeTimeoutOffset = 500 * time.Millisecond
+)
+
+// probeHandler serves the /probe?module=...&target=... requests in the spirit of blackbox_exporter,
+// it scrapes the target once with a new client and returns the metrics of the target only
+type probeHandler struct {
+	logger *slog.Logger
+}
+
+func (h *probeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	module, target := query.Get("module"), query.Get("target")
+
+	if !slices.Contains(probeModules, module) {
+		http.Error(w, fmt.Sprintf("unknown module %q, expected one of %s", module, strings.Join(probeModules, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	schemes := config.RESTSchemes
+	if module == "l2geth" || module == "wallet" {
+		schemes = config.RPCSchemes
+	}
+	if err := config.CheckURL(target, schemes); err != nil {
+		http.Error(w, fmt.Sprintf("target %s: %s", utils.RedactURL(target), err), http.StatusBadRequest)
+		return
+	}
+
+	var addr common.Address
+	if module == "wallet" {
+		if !common.IsHexAddress(query.Get("address")) {
+			http.Error(w, fmt.Sprintf("invalid address %q", query.Get("address")), http.StatusBadRequest)
+			return
+		}
+		addr = common.HexToAddress(query.Get("address"))
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), probeTimeout(r))
+	defer cancel()
+
+	reg := prometheus.NewRegistry()
+	success := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_success",
+		Help: "Whether the probe succeeded.",
+	})
+	duration := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_duration_seconds",
+		Help: "Duration of the probe in seconds.",
+	})
+	reg.MustRegister(success, duration)
+
+	start := time.Now()
+	var err error
+	if module == "wallet" {
+		err = h.probeWallet(ctx, reg, target, addr, query)
+	} else {
+		err = h.probeSequencer(ctx, reg, module, target, query)
+	}
+	duration.Set(time.Since(start).Seconds())
+
+	if err != nil {
+		h.logger.Error("probe", "module", module, "target", target, "err", err)
+	} else {
+		success.Set(1)
+	}
+
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// probeSequencer scrapes the service of a sequencer, the seq_name is the name parameter or the target url
+func (h *probeHandler) probeSequencer(ctx context.Context, reg prometheus.Registerer, module, target string, query url.Values) error {
+	name := query.Get("name")
+	if name == "" {
+		name = utils.RedactURL(target)
+	}
+
+	client := newSequencerClient(nil)
+	m := newSequencerMetric(reg, map[string]*SequencerClient{name: client}, nil, h.logger)
+
+	var err error
+	switch module {
+	case "l2geth":
+		client.l2rpc = ethrpc.New(target)
+		defer client.l2rpc.Close()
+		return m.scrapeL2geth(ctx, name, client)
+	case "themis":
+		if client.themis, err = themis.NewClient(target, nil); err != nil {
+			return err
+		}
+		return m.scrapeThemis(ctx, name, client)
+	default:
+		if client.dtl, err = dtl.NewClient(target, nil); err != nil {
+			return err
+		}
+		return m.scrapeL1DTL(ctx, name, client)
+	}
+}
+
+// probeWallet scrapes the balance and the nonces of the address,
+// the chain and the alias labels are the chain and the alias parameters
+func (h *probeHandler) probeWallet(ctx context.Context, reg prometheus.Registerer, target string, addr common.Address, query url.Values) error {
+	labels := prometheus.Labels{"chain": "eth", "addr": addr.Hex(), "alias": addr.Hex()}
+	for _, key := range []string{"chain", "alias"} {
+		if value := query.Get(key); value != "" {
+			labels[key] = value
+		}
+	}
+
+	balance, nonce, gap := newWalletBalanceVec(), newWalletNonceVec(), newWalletNonceGapVec()
+	reg.MustRegister(balance, nonce, gap)
+
+	client := ethrpc.New(target)
+	defer client.Close()
+
+	wei, err := client.BalanceAt(ctx, addr, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get balance: %s", err)
+	}
+	latest, err := client.NonceAt(ctx, addr, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get nonce: %s", err)
+	}
+	pending, err := client.PendingNonceAt(ctx, addr)
+	if err != nil {
+		return fmt.Errorf("failed to get pending nonce: %s", err)
+	}
+
+	balance.With(labels).Set(utils.ToEther(wei))
+	nonce.With(labels).Add(float64(latest))
+	gap.With(labels).Set(float64(nonceGap(latest, pending)))
+	return nil
+}
+
+// probeTimeout returns the scrape timeout of prometheus minus the offset, or the default one
+func probeTimeout(r *http.Request) time.Duration {
+	seconds, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64)
+	if err != nil || seconds <= 0 {
+		return defaultProbeTimeout
+	}
+	timeout := time.Duration(seconds*float64(time.Second)) - probeTimeoutOffset
+	return max(timeout, probeTimeoutOffset)
+}
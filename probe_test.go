@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestProbeHandler(t *testing.T) {
+	// a json-rpc server which replies 2 ether and the nonces 5 and 7 of the pending block
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params []any           `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request: %s", err)
+			return
+		}
+		var result any
+		switch req.Method {
+		case "eth_getBalance":
+			result = "0x1bc16d674ec80000"
+		case "eth_getTransactionCount":
+			result = "0x5"
+			if len(req.Params) > 1 && req.Params[1] == "pending" {
+				result = "0x7"
+			}
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := "http://" + listener.Addr().String()
+	listener.Close()
+
+	const addr = "0x0000000000000000000000000000000000000001"
+
+	tests := []struct {
+		name       string
+		query      url.Values
+		wantStatus int
+		want       []string
+	}{
+		{
+			name:       "wallet",
+			query:      url.Values{"module": {"wallet"}, "target": {server.URL}, "address": {addr}, "alias": {"sequencer"}},
+			wantStatus: http.StatusOK,
+			want: []string{
+				"probe_success 1",
+				`metis:sequencer:wallet:balance{addr="` + addr + `",alias="sequencer",chain="eth"} 2`,
+				`metis:sequencer:wallet:nonce{addr="` + addr + `",alias="sequencer",chain="eth"} 5`,
+				`metis:sequencer:wallet:nonce_gap{addr="` + addr + `",alias="sequencer",chain="eth"} 2`,
+			},
+		},
+		{
+			name:       "unreachable",
+			query:      url.Values{"module": {"l1dtl"}, "target": {unreachable}},
+			wantStatus: http.StatusOK,
+			want:       []string{"probe_success 0"},
+		},
+		{
+			name:       "unknown module",
+			query:      url.Values{"module": {"l1geth"}, "target": {server.URL}},
+			wantStatus: http.StatusBadRequest,
+			want:       []string{"unknown module"},
+		},
+		{
+			name:       "invalid target",
+			query:      url.Values{"module": {"themis"}, "target": {"ws://localhost:8545"}},
+			wantStatus: http.StatusBadRequest,
+			want:       []string{"target ws://localhost:8545"},
+		},
+		{
+			name:       "invalid address",
+			query:      url.Values{"module": {"wallet"}, "target": {server.URL}, "address": {"0x01"}},
+			wantStatus: http.StatusBadRequest,
+			want:       []string{"invalid address"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &probeHandler{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/probe?"+tt.query.Encode(), nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("body doesn't contain %q:\n%s", want, w.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestProbeTimeout(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: "10s"},
+		{header: "5", want: "4.5s"},
+		{header: "0.2", want: "500ms"},
+		{header: "invalid", want: "10s"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/probe", nil)
+		if tt.header != "" {
+			r.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", tt.header)
+		}
+		if got := probeTimeout(r).String(); got != tt.want {
+			t.Errorf("probeTimeout(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
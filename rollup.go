@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/collector"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/rollup"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type rollupMetric struct {
+	chains map[string]*rollup.Chain
+
+	totalElements *prometheus.GaugeVec
+	totalBatches  *prometheus.GaugeVec
+	lagBlocks     *prometheus.GaugeVec
+	lagSeconds    *prometheus.GaugeVec
+}
+
+func newRollupMetric(reg prometheus.Registerer, conf *config.Rollup, l1rpc ethereum.ContractCaller) *rollupMetric {
+	chains := map[string]*rollup.Chain{
+		"ctc": rollup.NewChain(l1rpc, conf.CTC, conf.ChainID),
+		"scc": rollup.NewChain(l1rpc, conf.SCC, conf.ChainID),
+	}
+
+	m := &rollupMetric{
+		chains: chains,
+		totalElements: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "metis:sequencer:rollup:total_elements",
+			Help: "Total elements committed to the L1 rollup contract",
+		}, []string{"contract"}),
+		totalBatches: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "metis:sequencer:rollup:total_batches",
+			Help: "Total batches committed to the L1 rollup contract",
+		}, []string{"contract"}),
+		lagBlocks: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "metis:sequencer:rollup:lag_blocks",
+			Help: "Number of L2 blocks not committed to the L1 rollup contract yet",
+		}, []string{"contract"}),
+		lagSeconds: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "metis:sequencer:rollup:lag_seconds",
+			Help: "Seconds between the L2 head and the last L2 block committed to the L1 rollup contract",
+		}, []string{"contract"}),
+	}
+
+	reg.MustRegister(m.totalElements, m.totalBatches, m.lagBlocks, m.lagSeconds)
+	return m
+}
+
+func (m *WalletMetric) scrapeRollup(ctx context.Context, target collector.Target) error {
+	name, chain := target.Instance, m.rollup.chains[target.Instance]
+	labels := prometheus.Labels{"contract": name}
+
+	head, err := m.l2rpc.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get l2 head: %s", err)
+	}
+
+	elements, err := chain.TotalElements(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get total elements: %s", err)
+	}
+
+	batches, err := chain.TotalBatches(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get total batches: %s", err)
+	}
+
+	// the element index i is the L2 block i+1, so the last committed block is the total elements
+	committed := elements
+	var lagBlocks, lagSeconds uint64
+	if head.Number.Uint64() > committed {
+		lagBlocks = head.Number.Uint64() - committed
+
+		header, err := m.l2rpc.HeaderByNumber(ctx, new(big.Int).SetUint64(committed))
+		if err != nil {
+			return fmt.Errorf("failed to get l2 block %d: %s", committed, err)
+		}
+		if head.Time > header.Time {
+			lagSeconds = head.Time - header.Time
+		}
+	}
+
+	m.logger.Debug("rollup", "contract", name, "elements", elements, "batches", batches, "head", head.Number, "lag", lagBlocks)
+
+	m.rollup.totalElements.With(labels).Set(float64(elements))
+	m.rollup.totalBatches.With(labels).Set(float64(batches))
+	m.rollup.lagBlocks.With(labels).Set(float64(lagBlocks))
+	m.rollup.lagSeconds.With(labels).Set(float64(lagSeconds))
+	return nil
+}
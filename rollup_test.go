@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/collector"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/ethrpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeRollupCaller replies the element and the batch counts of the rollup contracts
+type fakeRollupCaller struct {
+	elements, batches uint64
+}
+
+func (c *fakeRollupCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if bytes.Equal(call.Data, crypto.Keccak256([]byte("getTotalElements()"))[:4]) {
+		return math.U256Bytes(new(big.Int).SetUint64(c.elements)), nil
+	}
+	return math.U256Bytes(new(big.Int).SetUint64(c.batches)), nil
+}
+
+// rollupBlockTime is the timestamp of the l2 block on the fake l2geth, a block every 2 seconds
+func rollupBlockTime(number uint64) uint64 {
+	return 1700000000 + number*2
+}
+
+// newFakeRollupL2Geth returns a json-rpc server of the blocks up to the head, the read blocks are recorded
+func newFakeRollupL2Geth(t *testing.T, head uint64, read *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request: %s", err)
+			return
+		}
+		if req.Method != "eth_getBlockByNumber" {
+			t.Errorf("unexpected method %s", req.Method)
+		}
+
+		number := head
+		if param := string(req.Params[0]); param != `"latest"` {
+			var n hexutil.Uint64
+			if err := json.Unmarshal(req.Params[0], &n); err != nil {
+				t.Errorf("invalid block number %s: %s", param, err)
+			}
+			number = uint64(n)
+		}
+		*read = append(*read, string(req.Params[0]))
+
+		var result *types.Header
+		if number <= head {
+			result = &types.Header{Number: new(big.Int).SetUint64(number), Difficulty: common.Big0, Time: rollupBlockTime(number)}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+}
+
+func TestWalletMetric_ScrapeRollup(t *testing.T) {
+	tests := []struct {
+		name           string
+		head           uint64
+		elements       uint64
+		wantLagBlocks  float64
+		wantLagSeconds float64
+		// wantRead is the blocks read from l2geth
+		wantRead []string
+	}{
+		{
+			name:           "behind",
+			head:           110,
+			elements:       100,
+			wantLagBlocks:  10,
+			wantLagSeconds: 20,
+			wantRead:       []string{`"latest"`, `"0x64"`},
+		},
+		{
+			name:     "caught-up",
+			head:     100,
+			elements: 100,
+			wantRead: []string{`"latest"`},
+		},
+		{
+			// the head of a lagging l2geth can be behind the committed elements
+			name:     "ahead",
+			head:     90,
+			elements: 100,
+			wantRead: []string{`"latest"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var read []string
+			server := newFakeRollupL2Geth(t, tt.head, &read)
+			defer server.Close()
+
+			caller := &fakeRollupCaller{elements: tt.elements, batches: 7}
+			m := &WalletMetric{
+				l2rpc:  ethrpc.New(server.URL),
+				rollup: newRollupMetric(prometheus.NewRegistry(), &config.Rollup{}, caller),
+				logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			defer m.l2rpc.Close()
+
+			if err := m.scrapeRollup(context.Background(), collector.Target{Instance: "ctc"}); err != nil {
+				t.Fatalf("WalletMetric.scrapeRollup() error = %v", err)
+			}
+
+			if got := testutil.ToFloat64(m.rollup.totalElements.WithLabelValues("ctc")); got != float64(tt.elements) {
+				t.Errorf("total_elements = %v, want %v", got, tt.elements)
+			}
+			if got := testutil.ToFloat64(m.rollup.totalBatches.WithLabelValues("ctc")); got != 7 {
+				t.Errorf("total_batches = %v, want 7", got)
+			}
+			if got := testutil.ToFloat64(m.rollup.lagBlocks.WithLabelValues("ctc")); got != tt.wantLagBlocks {
+				t.Errorf("lag_blocks = %v, want %v", got, tt.wantLagBlocks)
+			}
+			if got := testutil.ToFloat64(m.rollup.lagSeconds.WithLabelValues("ctc")); got != tt.wantLagSeconds {
+				t.Errorf("lag_seconds = %v, want %v", got, tt.wantLagSeconds)
+			}
+			if !slices.Equal(read, tt.wantRead) {
+				t.Errorf("read blocks = %v, want %v", read, tt.wantRead)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/metis-devops/metis-sequencer-exporter/internal/collector"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+type promRule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+type promRuleGroup struct {
+	Name     string      `yaml:"name"`
+	Interval string      `yaml:"interval"`
+	Rules    []*promRule `yaml:"rules"`
+}
+
+type promRuleFile struct {
+	Groups []*promRuleGroup `yaml:"groups"`
+}
+
+type rulesOptions struct {
+	SequencerInterval time.Duration
+	WalletInterval    time.Duration
+	SpanThreshold     uint64
+	RunwayThreshold   time.Duration
+}
+
+func rulesCommand(args []string) int {
+	var (
+		confPath string
+		output   string
+		opts     rulesOptions
+	)
+
+	fs := flag.NewFlagSet("rules", flag.ExitOnError)
+	fs.StringVar(&confPath, "config", "config.yaml", "config path")
+	fs.StringVar(&output, "output", "", "the rules file path, print to stdout if it's empty")
+	fs.DurationVar(&opts.SequencerInterval, "interval.sequencer", time.Second*15, "the default scrape interval, which is overridden by the config")
+	fs.DurationVar(&opts.WalletInterval, "interval.wallet", time.Minute, "the default scrape interval, which is overridden by the config")
+	fs.Uint64Var(&opts.SpanThreshold, "span.threshold", 500, "warn if the span ends within the blocks")
+	fs.DurationVar(&opts.RunwayThreshold, "wallet.runway", time.Hour*72, "warn if the wallet balance runs out within the duration")
+	_ = fs.Parse(args)
+
+	conf, err := config.Parse(confPath)
+	if err != nil {
+		slog.Error("config", "path", confPath, "err", err)
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			slog.Error("create rules file", "path", output, "err", err)
+			return 1
+		}
+		defer file.Close() //nolint:errcheck
+		w = file
+	}
+
+	if err := writeRules(w, generateRules(conf, opts)); err != nil {
+		slog.Error("write rules", "err", err)
+		return 1
+	}
+	return 0
+}
+
+func writeRules(w io.Writer, rules *promRuleFile) error {
+	if _, err := fmt.Fprintln(w, "# Generated by metis-sequencer-exporter rules, DO NOT EDIT."); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w, "# yaml-language-server: $schema=https://json.schemastore.org/prometheus.rules.json"); err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(rules); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// generateRules returns the alerting rules of the configured services
+func generateRules(conf *config.Config, opts rulesOptions) *promRuleFile {
+	var (
+		balanceRules   []*promRule
+		hasThemis      bool
+		hasDTL         bool
+		hasWallet      bool
+		hasBlobAddress bool
+	)
+
+	for _, network := range utils.SortedKeys(conf.Networks) {
+		netconf := conf.Networks[network]
+		for _, seq := range netconf.Sequencers {
+			hasThemis = hasThemis || seq.Themis != ""
+			hasDTL = hasDTL || seq.L1DTL != ""
+		}
+
+		if netconf.Wallet == nil {
+			continue
+		}
+		hasWallet = true
+		hasBlobAddress = hasBlobAddress || netconf.Wallet.Themis != ""
+
+		for chain, thresholds := range map[string]map[string]float64{
+			"eth":   netconf.Wallet.MinBalance,
+			"metis": netconf.Wallet.L2MinBalance,
+		} {
+			for _, alias := range utils.SortedKeys(thresholds) {
+				balanceRules = append(balanceRules, &promRule{
+					Alert: "WalletBalanceInsufficient",
+					Expr: fmt.Sprintf("metis:sequencer:wallet:balance{network='%s',chain='%s',alias='%s'} < %v",
+						network, chain, alias, thresholds[alias]),
+					Labels: map[string]string{"severity": "high"},
+					Annotations: map[string]string{
+						"summary": fmt.Sprintf("The balance of %s on %s of %s is less than %v", alias, chain, network, thresholds[alias]),
+					},
+				})
+			}
+		}
+	}
+
+	// the windows cover several rounds of the slowest target, whose interval may be set in the config
+	heightInterval := sequencerInterval(conf, opts.SequencerInterval, config.SequencerEndpoints...)
+	scrapeInterval := max(
+		sequencerInterval(conf, opts.SequencerInterval, config.SequencerServices...),
+		walletInterval(conf, opts.WalletInterval, config.WalletServices...),
+	)
+
+	stallWindow := alertWindow(heightInterval, 2*time.Minute)
+	rules := []*promRule{
+		{
+			Alert:  "ChainStalled",
+			Expr:   fmt.Sprintf("increase(metis:sequencer:height[%s]) == 0", promDuration(stallWindow)),
+			Labels: map[string]string{"severity": "critical"},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("{{ $labels.svc_name }} of {{ $labels.seq_name }} has no new blocks in the past %s", promDuration(stallWindow)),
+			},
+		},
+	}
+
+	failureWindow := alertWindow(scrapeInterval, time.Minute)
+	rules = append(rules, &promRule{
+		Alert:  "ScrapeFailures",
+		Expr:   fmt.Sprintf("increase(metis_sequencer_exporter_failures[%s]) > 2", promDuration(failureWindow)),
+		For:    promDuration(alertWindow(scrapeInterval, 3*time.Minute)),
+		Labels: map[string]string{"severity": "high"},
+		Annotations: map[string]string{
+			"summary": "Failed to scrape metrics of {{ $labels.svc_name }}, see the exporter log to fix it",
+		},
+	})
+
+	if hasThemis {
+		rules = append(rules, &promRule{
+			Alert:  "SpanEnding",
+			Expr:   fmt.Sprintf("metis:sequencer:span:remaining_blocks < %d", opts.SpanThreshold),
+			For:    promDuration(alertWindow(sequencerInterval(conf, opts.SequencerInterval, "themis"), 5*time.Minute)),
+			Labels: map[string]string{"severity": "high"},
+			Annotations: map[string]string{
+				"summary": "The span of {{ $labels.seq_name }} ends in {{ $value }} blocks",
+			},
+		})
+	}
+
+	if hasDTL {
+		rules = append(rules, &promRule{
+			Alert:  "StateRootMismatch",
+			Expr:   "increase(metis:sequencer:stateroot:mismatches[5m]) > 0",
+			Labels: map[string]string{"severity": "critical"},
+			Annotations: map[string]string{
+				"summary": "State roots committed to L1 differ from l2geth of {{ $labels.seq_name }}",
+			},
+		})
+	}
+
+	if hasWallet {
+		walletWindow := alertWindow(walletInterval(conf, opts.WalletInterval, "l1geth", "l2geth"), 10*time.Minute)
+		rules = append(rules,
+			&promRule{
+				Alert:  "WalletRunwayShort",
+				Expr:   fmt.Sprintf("metis:sequencer:wallet:time_to_empty < %d", int64(opts.RunwayThreshold.Seconds())),
+				For:    promDuration(walletWindow),
+				Labels: map[string]string{"severity": "high"},
+				Annotations: map[string]string{
+					"summary": fmt.Sprintf("The balance of {{ $labels.alias }} on {{ $labels.chain }} will run out in less than %s", promDuration(opts.RunwayThreshold)),
+				},
+			},
+			&promRule{
+				Alert:  "WalletNonceStuck",
+				Expr:   fmt.Sprintf("metis:sequencer:wallet:nonce_gap_duration > %d", int64(walletWindow.Seconds())),
+				Labels: map[string]string{"severity": "critical"},
+				Annotations: map[string]string{
+					"summary": fmt.Sprintf("{{ $labels.alias }} on {{ $labels.chain }} has pending transactions stuck for more than %s", promDuration(walletWindow)),
+				},
+			},
+			&promRule{
+				Alert:  "WalletTxReverted",
+				Expr:   fmt.Sprintf("increase(metis:sequencer:wallet:tx_reverted[%s]) > 0", promDuration(alertWindow(walletInterval(conf, opts.WalletInterval, "txs"), 5*time.Minute))),
+				Labels: map[string]string{"severity": "critical"},
+				Annotations: map[string]string{
+					"summary": "{{ $labels.alias }} has reverted transactions on {{ $labels.chain }}",
+				},
+			},
+		)
+	}
+
+	if hasBlobAddress {
+		rules = append(rules, &promRule{
+			Alert:  "BlobSubmissionStale",
+			Expr:   "metis:sequencer:wallet:blob_age{alias='BlobSubmitMpcAddr'} > 3600",
+			Labels: map[string]string{"severity": "high"},
+			Annotations: map[string]string{
+				"summary": "No blobs have been submitted by {{ $labels.alias }} in the past hour",
+			},
+		}, &promRule{
+			// the blob age is unknown until the first submission since the exporter started
+			Alert:  "BlobSubmissionUnseen",
+			Expr:   "metis:sequencer:wallet:blob_tx_sent{alias='BlobSubmitMpcAddr'} unless metis:sequencer:wallet:blob_age{alias='BlobSubmitMpcAddr'}",
+			For:    "1h",
+			Labels: map[string]string{"severity": "high"},
+			Annotations: map[string]string{
+				"summary": "No blobs have been submitted by {{ $labels.alias }} since the exporter started an hour ago",
+			},
+		})
+	}
+
+	sort.Slice(balanceRules, func(i, j int) bool { return balanceRules[i].Expr < balanceRules[j].Expr })
+	rules = append(rules, balanceRules...)
+
+	return &promRuleFile{
+		Groups: []*promRuleGroup{
+			{Name: "metis-seq", Interval: "1m", Rules: rules},
+		},
+	}
+}
+
+// sequencerInterval returns the largest scrape interval of the services over the sequencers of all networks,
+// the interval is resolved from the default one and the config in the same way as the scrape loops
+func sequencerInterval(conf *config.Config, interval time.Duration, services ...string) time.Duration {
+	var res time.Duration
+	for _, netconf := range conf.Networks {
+		for _, seq := range netconf.Sequencers {
+			for _, svc := range services {
+				if !hasSequencerService(seq, svc) {
+					continue
+				}
+				if opts := collector.NewOptions(interval, netconf.Scrape.Service(svc), seq.Scrape.Service(svc)); opts.Enabled {
+					res = max(res, opts.Interval)
+				}
+			}
+		}
+	}
+	if res == 0 {
+		return interval
+	}
+	return res
+}
+
+// hasSequencerService returns whether the service of the sequencer is scraped
+func hasSequencerService(seq *config.Sequencer, svc string) bool {
+	switch svc {
+	case "themis":
+		return seq.Themis != ""
+	case "l1dtl", "stateroot":
+		return seq.L1DTL != ""
+	}
+	return true
+}
+
+// walletInterval returns the largest scrape interval of the services over the wallets of all networks
+func walletInterval(conf *config.Config, interval time.Duration, services ...string) time.Duration {
+	var res time.Duration
+	for _, netconf := range conf.Networks {
+		if netconf.Wallet == nil {
+			continue
+		}
+		for _, svc := range services {
+			if opts := collector.NewOptions(interval, netconf.Wallet.Scrape.Service(svc)); opts.Enabled {
+				res = max(res, opts.Interval)
+			}
+		}
+	}
+	if res == 0 {
+		return interval
+	}
+	return res
+}
+
+// alertWindow returns a window which covers several scrape rounds
+func alertWindow(interval, minimum time.Duration) time.Duration {
+	window := 4 * interval
+	if window < minimum {
+		return minimum
+	}
+	return window.Round(time.Minute)
+}
+
+// promDuration formats the duration in the prometheus style like 1h30m
+func promDuration(d time.Duration) string {
+	var res string
+	if h := d / time.Hour; h > 0 {
+		res += fmt.Sprintf("%dh", h)
+		d -= h * time.Hour
+	}
+	if m := d / time.Minute; m > 0 {
+		res += fmt.Sprintf("%dm", m)
+		d -= m * time.Minute
+	}
+	if s := d / time.Second; s > 0 || res == "" {
+		res += fmt.Sprintf("%ds", s)
+	}
+	return res
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/collector"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/transport"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// descRecorder records the metric names of the registered collectors
+type descRecorder struct {
+	names map[string]bool
+}
+
+var fqNameRegexp = regexp.MustCompile(`fqName: "([^"]+)"`)
+
+func (r *descRecorder) Register(c prometheus.Collector) error {
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(ch)
+		close(ch)
+	}()
+	for desc := range ch {
+		if match := fqNameRegexp.FindStringSubmatch(desc.String()); match != nil {
+			r.names[match[1]] = true
+		}
+	}
+	return nil
+}
+
+func (r *descRecorder) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		_ = r.Register(c)
+	}
+}
+
+func (r *descRecorder) Unregister(prometheus.Collector) bool { return true }
+
+func TestGenerateRules(t *testing.T) {
+	netconf := &config.Network{
+		Sequencers: map[string]*config.Sequencer{
+			"node-0": {L2Geth: "http://127.0.0.1:8545", L1DTL: "http://127.0.0.1:7878", Themis: "http://127.0.0.1:1317"},
+		},
+		Wallet: &config.Wallet{
+			L1Geth:       config.URLs{"http://127.0.0.1:8545"},
+			L2Geth:       config.URLs{"http://127.0.0.1:8545"},
+			Wallets:      map[string]common.Address{"custom": common.HexToAddress("0x01")},
+			MinBalance:   map[string]float64{"custom": 0.5},
+			L2MinBalance: map[string]float64{"custom": 10},
+		},
+	}
+	conf := &config.Config{Networks: map[string]*config.Network{"mainnet": netconf}}
+
+	recorder := &descRecorder{names: make(map[string]bool)}
+	collector.NewScheduler(recorder, slog.Default())
+	pool := transport.NewPool(recorder, transport.DefaultOptions)
+	if _, err := NewSeqMetric(recorder, "mainnet", netconf, pool); err != nil {
+		t.Fatalf("NewSeqMetric() error = %v", err)
+	}
+	if _, err := NewWalletMetric(recorder, "mainnet", netconf, pool, time.Hour); err != nil {
+		t.Fatalf("NewWalletMetric() error = %v", err)
+	}
+
+	rules := generateRules(conf, rulesOptions{
+		SequencerInterval: 15 * time.Second,
+		WalletInterval:    time.Minute,
+		SpanThreshold:     500,
+		RunwayThreshold:   72 * time.Hour,
+	})
+
+	metricRegexp := regexp.MustCompile(`metis[:_][a-z0-9_:]+`)
+	var balanceRules int
+	for _, rule := range rules.Groups[0].Rules {
+		for _, name := range metricRegexp.FindAllString(rule.Expr, -1) {
+			if !recorder.names[name] {
+				t.Errorf("rule %s references metric %s which is not exposed", rule.Alert, name)
+			}
+		}
+		for _, text := range rule.Annotations {
+			if strings.Contains(text, "$labels.url") {
+				t.Errorf("rule %s references the label url which is not exposed", rule.Alert)
+			}
+		}
+		if rule.Alert == "WalletBalanceInsufficient" {
+			balanceRules++
+		}
+	}
+
+	if balanceRules != 2 {
+		t.Errorf("generateRules() has %d balance rules, want 2", balanceRules)
+	}
+}
+
+func TestGenerateRules_Windows(t *testing.T) {
+	interval := func(d time.Duration) *config.Scrape {
+		v := config.Duration(d)
+		return &config.Scrape{Interval: &v}
+	}
+	conf := &config.Config{Networks: map[string]*config.Network{
+		"mainnet": {
+			Sequencers: map[string]*config.Sequencer{
+				"node-0": {L2Geth: "http://127.0.0.1:8545", Themis: "http://127.0.0.1:1317"},
+				// the sequencer overrides the interval of the network
+				"node-1": {L2Geth: "http://127.0.0.1:8546", Scrape: &config.SequencerScrape{L2Geth: interval(time.Minute)}},
+			},
+			Scrape: &config.SequencerScrape{L2Geth: interval(30 * time.Second), Themis: interval(5 * time.Minute)},
+		},
+		"sepolia": {
+			Wallet: &config.Wallet{
+				L1Geth: config.URLs{"http://127.0.0.1:8545"},
+				L2Geth: config.URLs{"http://127.0.0.1:8545"},
+				Scrape: &config.WalletScrape{Txs: interval(10 * time.Minute)},
+			},
+		},
+	}}
+
+	rules := generateRules(conf, rulesOptions{
+		SequencerInterval: 15 * time.Second,
+		WalletInterval:    time.Minute,
+		SpanThreshold:     500,
+		RunwayThreshold:   72 * time.Hour,
+	})
+
+	got := make(map[string]*promRule)
+	for _, rule := range rules.Groups[0].Rules {
+		got[rule.Alert] = rule
+	}
+	tests := []struct {
+		alert string
+		value string
+		want  string
+	}{
+		{alert: "ChainStalled", value: got["ChainStalled"].Expr, want: "[20m]"},
+		{alert: "ScrapeFailures", value: got["ScrapeFailures"].Expr, want: "[40m]"},
+		{alert: "ScrapeFailures", value: got["ScrapeFailures"].For, want: "40m"},
+		{alert: "SpanEnding", value: got["SpanEnding"].For, want: "20m"},
+		{alert: "WalletRunwayShort", value: got["WalletRunwayShort"].For, want: "10m"},
+		{alert: "WalletTxReverted", value: got["WalletTxReverted"].Expr, want: "[40m]"},
+	}
+	for _, tt := range tests {
+		if !strings.Contains(tt.value, tt.want) {
+			t.Errorf("window of %s = %q, want %s", tt.alert, tt.value, tt.want)
+		}
+	}
+}
+
+func TestPromDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{30 * time.Second, "30s"},
+		{2 * time.Minute, "2m"},
+		{90 * time.Minute, "1h30m"},
+		{72 * time.Hour, "72h"},
+	}
+	for _, tt := range tests {
+		if got := promDuration(tt.d); got != tt.want {
+			t.Errorf("promDuration(%s) = %v, want %v", tt.d, got, tt.want)
+		}
+	}
+}
@@ -4,73 +4,106 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"os"
 	"sync"
 	"time"
 
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/collector"
 	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
 	"github.com/metis-devops/metis-sequencer-exporter/internal/dtl"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/ethrpc"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/logging"
 	"github.com/metis-devops/metis-sequencer-exporter/internal/themis"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/transport"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/utils"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 type SequencerClient struct {
-	l2rpc          *ethclient.Client
+	l2rpc          *ethrpc.Client
 	dtl            *dtl.Client
 	themis         *themis.Client
 	lastHeights    map[string]float64
 	lastTimestamps map[string]float64
+	scrape         *config.SequencerScrape
 	mutex          sync.Mutex
+
+	// the verification starts from the latest batch when the exporter starts
+	stateRootStarted    bool
+	nextStateRootBatch  uint64
+	firstMismatchIndex  uint64
+	stateRootMismatched bool
+}
+
+func newSequencerClient(scrape *config.SequencerScrape) *SequencerClient {
+	return &SequencerClient{
+		lastHeights:    make(map[string]float64),
+		lastTimestamps: make(map[string]float64),
+		scrape:         scrape,
+	}
 }
 
 type SequencerMetric struct {
 	clients    map[string]*SequencerClient
 	timestamps *prometheus.CounterVec
 	heights    *prometheus.CounterVec
-	logger     *slog.Logger
-}
+	stateRoots *stateRootMetric
+
+	spanID        *prometheus.GaugeVec
+	spanEnd       *prometheus.GaugeVec
+	spanRemaining *prometheus.GaugeVec
 
-func NewSeqMetric(basectx context.Context, reg prometheus.Registerer, conf *config.Config) (*SequencerMetric, error) {
-	ctx, cancel := context.WithTimeout(basectx, time.Minute)
-	defer cancel()
+	// the default schedule of the services
+	scrape *config.SequencerScrape
+	logger *slog.Logger
+}
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, nil)).With("module", "sequencer")
+// NewSeqMetric doesn't connect to the services, the unreachable ones fail the scrapes instead of the startup
+func NewSeqMetric(reg prometheus.Registerer, network string, conf *config.Network, pool *transport.Pool) (*SequencerMetric, error) {
+	logger := logging.New("sequencer").With("network", network)
 
 	var clients = make(map[string]*SequencerClient)
-	for name, ep := range conf.Sequencers {
-		client := &SequencerClient{
-			lastHeights:    make(map[string]float64),
-			lastTimestamps: make(map[string]float64),
-		}
+	// the sequencers are added in order, so the endpoint labels of the pool are stable
+	for _, name := range utils.SortedKeys(conf.Sequencers) {
+		ep := conf.Sequencers[name]
+		client := newSequencerClient(ep.Scrape)
 
-		var err error
-		logger.Info("connect to l2geth", "name", name, "url", ep.L2Geth)
-		client.l2rpc, err = ethclient.DialContext(ctx, ep.L2Geth)
+		logger.Info("connect to l2geth", "name", name, "url", utils.RedactURL(ep.L2Geth))
+		opts, err := pool.RPCOptions(ep.L2Geth, ep.Clients.Service("l2geth"))
 		if err != nil {
-			return nil, fmt.Errorf("connect to l2geth %s of %s", ep.L2Geth, name)
+			return nil, fmt.Errorf("connect to l2geth %s of %s: %s", utils.RedactURL(ep.L2Geth), name, err)
 		}
+		client.l2rpc = ethrpc.New(ep.L2Geth, opts...)
 
 		if ep.L1DTL != "" {
-			logger.Info("connect to l1dtl", "name", name, "url", ep.L1DTL)
-			client.dtl, err = dtl.NewClient(ep.L1DTL)
+			logger.Info("connect to l1dtl", "name", name, "url", utils.RedactURL(ep.L1DTL))
+			httpClient, err := pool.HTTPClient(ep.L1DTL, ep.Clients.Service("l1dtl"))
+			if err == nil {
+				client.dtl, err = dtl.NewClient(ep.L1DTL, httpClient)
+			}
 			if err != nil {
-				return nil, fmt.Errorf("connect to l1dtl %s of %s", ep.L1DTL, name)
-
+				return nil, fmt.Errorf("connect to l1dtl %s of %s: %s", utils.RedactURL(ep.L1DTL), name, err)
 			}
 		}
 
 		if ep.Themis != "" {
-			logger.Info("connect to themis", "name", name, "url", ep.Themis)
-			client.themis, err = themis.NewClient(ep.Themis)
+			logger.Info("connect to themis", "name", name, "url", utils.RedactURL(ep.Themis))
+			httpClient, err := pool.HTTPClient(ep.Themis, ep.Clients.Service("themis"))
+			if err == nil {
+				client.themis, err = themis.NewClient(ep.Themis, httpClient)
+			}
 			if err != nil {
-				return nil, fmt.Errorf("connect to themis %s of %s", ep.Themis, name)
+				return nil, fmt.Errorf("connect to themis %s of %s: %s", utils.RedactURL(ep.Themis), name, err)
 			}
 		}
 
 		clients[name] = client
 	}
 
+	return newSequencerMetric(reg, clients, conf.Scrape, logger), nil
+}
+
+// newSequencerMetric registers the metrics of the clients
+func newSequencerMetric(reg prometheus.Registerer, clients map[string]*SequencerClient, scrape *config.SequencerScrape, logger *slog.Logger) *SequencerMetric {
 	m := &SequencerMetric{
 		clients: clients,
 		timestamps: prometheus.NewCounterVec(
@@ -87,205 +120,135 @@ func NewSeqMetric(basectx context.Context, reg prometheus.Registerer, conf *conf
 			},
 			[]string{"svc_name", "seq_name"},
 		),
+		stateRoots: newStateRootMetric(reg),
+		spanID: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "metis:sequencer:span:id",
+				Help: "Current span ID from themis.",
+			},
+			[]string{"seq_name"},
+		),
+		spanEnd: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "metis:sequencer:span:end_block",
+				Help: "End block of the current span from themis.",
+			},
+			[]string{"seq_name"},
+		),
+		spanRemaining: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "metis:sequencer:span:remaining_blocks",
+				Help: "Number of L2 blocks until the end of the current span.",
+			},
+			[]string{"seq_name"},
+		),
+		scrape: scrape,
 		logger: logger,
 	}
 
-	reg.MustRegister(m.timestamps, m.heights)
-	return m, nil
+	reg.MustRegister(m.timestamps, m.heights, m.spanID, m.spanEnd, m.spanRemaining)
+	return m
 }
 
-func (m *SequencerMetric) Scrape(basectx context.Context, failureCounter *prometheus.CounterVec, scrapeInterval time.Duration) {
-	go m.scrapeL2gethMetrics(basectx, failureCounter, scrapeInterval)
-	go m.scrapeThemisMetrics(basectx, failureCounter, scrapeInterval)
-	go m.scrapeL1DTLMetrics(basectx, failureCounter, scrapeInterval)
+// Probes returns the probes of the sequencer services, which scrape the service of every sequencer
+func (m *SequencerMetric) Probes(scrapeInterval time.Duration) []collector.Probe {
+	return []collector.Probe{
+		m.newProbe("l2geth", scrapeInterval, nil, m.scrapeL2geth),
+		m.newProbe("themis", scrapeInterval, func(c *SequencerClient) bool { return c.themis != nil }, m.scrapeThemis),
+		m.newProbe("l1dtl", scrapeInterval, func(c *SequencerClient) bool { return c.dtl != nil }, m.scrapeL1DTL),
+		m.newProbe("stateroot", scrapeInterval, func(c *SequencerClient) bool { return c.dtl != nil }, m.scrapeStateRoot),
+	}
 }
 
-func (m *SequencerMetric) scrapeL2gethMetrics(basectx context.Context, failureCounter *prometheus.CounterVec, scrapeInterval time.Duration) {
-	ticker := time.NewTimer(0)
-	defer ticker.Stop()
-
-	scrape := func(name string, client *SequencerClient) error {
-		newctx, cancel := context.WithTimeout(basectx, time.Minute)
-		defer cancel()
-
-		header, err := client.l2rpc.HeaderByNumber(newctx, nil)
-		if err != nil {
-			return fmt.Errorf("failed to get l2 height: %s", err)
-		}
+// newProbe returns the probe of the service whose targets are the sequencers which have it,
+// the schedule of every target is resolved from the network and the sequencer config
+func (m *SequencerMetric) newProbe(svc string, scrapeInterval time.Duration, has func(*SequencerClient) bool,
+	scrape func(ctx context.Context, name string, client *SequencerClient) error) collector.Probe {
+	var targets []collector.Target
+	for name, client := range m.clients {
+		if has != nil && !has(client) {
+			continue
+		}
+		targets = append(targets, collector.Target{
+			Name:     fmt.Sprintf("seq-%s-%s", name, svc),
+			Instance: name,
+			Options:  collector.NewOptions(scrapeInterval, m.scrape.Service(svc), client.scrape.Service(svc)),
+		})
+	}
+	if len(targets) == 0 {
+		m.logger.Warn("metric is disabled", "target", svc)
+	}
 
-		m.logger.Info("l2geth", "name", name, "height", header.Number, "timestamp", header.Time)
+	return collector.NewProbe("sequencer/"+svc, targets, func(ctx context.Context, target collector.Target) error {
+		return scrape(ctx, target.Instance, m.clients[target.Instance])
+	})
+}
 
-		client.mutex.Lock()
-		defer client.mutex.Unlock()
+func (m *SequencerMetric) scrapeL2geth(ctx context.Context, name string, client *SequencerClient) error {
+	header, err := client.l2rpc.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get l2 height: %s", err)
+	}
 
-		if t := float64(header.Time) - client.lastTimestamps["l2geth"]; t > 0 {
-			m.timestamps.With(prometheus.Labels{"svc_name": "l2geth", "seq_name": name}).Add(t)
-			client.lastTimestamps["l2geth"] += t
-		}
+	m.logger.Debug("l2geth", "name", name, "height", header.Number, "timestamp", header.Time)
 
-		if t := float64(header.Number.Int64()) - client.lastHeights["l2geth"]; t > 0 {
-			m.heights.With(prometheus.Labels{"svc_name": "l2geth", "seq_name": name}).Add(t)
-			client.lastHeights["l2geth"] += t
-		}
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
 
-		return nil
+	if t := float64(header.Time) - client.lastTimestamps["l2geth"]; t > 0 {
+		m.timestamps.With(prometheus.Labels{"svc_name": "l2geth", "seq_name": name}).Add(t)
+		client.lastTimestamps["l2geth"] += t
 	}
 
-	for {
-		select {
-		case <-basectx.Done():
-			return
-		case <-ticker.C:
-			var wg sync.WaitGroup
-			var start = time.Now()
-			for name, client := range m.clients {
-				wg.Add(1)
-				name, client := name, client
-				go func() {
-					if err := scrape(name, client); err != nil {
-						failureCounter.With(prometheus.Labels{"svc_name": fmt.Sprintf("seq-%s-l2geth", name)}).Inc()
-						m.logger.Error("scrape l2geth metrics", "seq", name, "err", err)
-					}
-					wg.Done()
-				}()
-			}
-			wg.Wait()
-			m.logger.Info("Done", "target", "l2geth", "duration", time.Since(start))
-			ticker.Reset(scrapeInterval)
-		}
+	if t := float64(header.Number.Int64()) - client.lastHeights["l2geth"]; t > 0 {
+		m.heights.With(prometheus.Labels{"svc_name": "l2geth", "seq_name": name}).Add(t)
+		client.lastHeights["l2geth"] += t
 	}
+
+	return nil
 }
 
-func (m *SequencerMetric) scrapeThemisMetrics(basectx context.Context, failureCounter *prometheus.CounterVec, scrapeInterval time.Duration) {
-	if enabled := func() bool {
-		for _, i := range m.clients {
-			if i.themis != nil {
-				return true
-			}
-		}
-		return false
-	}(); !enabled {
-		m.logger.Warn("PoS metric is disabled")
-		return
+func (m *SequencerMetric) scrapeThemis(ctx context.Context, name string, client *SequencerClient) error {
+	height, epoch, err := client.themis.LatestEpoch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get epoch info: %s", err)
 	}
 
-	ticker := time.NewTimer(0)
-	defer ticker.Stop()
+	m.logger.Debug("themis", "name", name, "height", height, "span", epoch.ID)
 
-	scrape := func(name string, client *SequencerClient) error {
-		if client.themis == nil {
-			return nil
-		}
-
-		newctx, cancel := context.WithTimeout(basectx, time.Minute)
-		defer cancel()
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
 
-		height, epoch, err := client.themis.LatestEpoch(newctx)
-		if err != nil {
-			return fmt.Errorf("failed to get epoch info: %s", err)
-		}
-
-		m.logger.Info("themis", "name", name, "height", height, "span", epoch.ID)
-
-		client.mutex.Lock()
-		defer client.mutex.Unlock()
-
-		if t := float64(height) - client.lastHeights["themis"]; t > 0 {
-			m.heights.With(prometheus.Labels{"svc_name": "themis", "seq_name": name}).Add(t)
-			client.lastHeights["themis"] += t
-		}
-
-		return nil
+	if t := float64(height) - client.lastHeights["themis"]; t > 0 {
+		m.heights.With(prometheus.Labels{"svc_name": "themis", "seq_name": name}).Add(t)
+		client.lastHeights["themis"] += t
 	}
 
-	for {
-		select {
-		case <-basectx.Done():
-			return
-		case <-ticker.C:
-			var wg sync.WaitGroup
-			var start = time.Now()
-			for name, client := range m.clients {
-				wg.Add(1)
-				name, client := name, client
-				go func() {
-					if err := scrape(name, client); err != nil {
-						failureCounter.With(prometheus.Labels{"svc_name": fmt.Sprintf("seq-%s-themis", name)}).Inc()
-						m.logger.Error("scrape themis metrics", "seq", name, "err", err)
-					}
-					wg.Done()
-				}()
-			}
-			wg.Wait()
-			m.logger.Info("Done", "target", "themis", "duration", time.Since(start))
-			ticker.Reset(scrapeInterval)
-		}
+	labels := prometheus.Labels{"seq_name": name}
+	m.spanID.With(labels).Set(float64(epoch.ID))
+	m.spanEnd.With(labels).Set(float64(epoch.EndBlock))
+	if l2height, ok := client.lastHeights["l2geth"]; ok {
+		m.spanRemaining.With(labels).Set(float64(epoch.EndBlock) - l2height)
 	}
+
+	return nil
 }
 
-func (m *SequencerMetric) scrapeL1DTLMetrics(basectx context.Context, failureCounter *prometheus.CounterVec, scrapeInterval time.Duration) {
-	if enabled := func() bool {
-		for _, i := range m.clients {
-			if i.dtl != nil {
-				return true
-			}
-		}
-		return false
-	}(); !enabled {
-		m.logger.Warn("DTL metric is disabled")
-		return
+func (m *SequencerMetric) scrapeL1DTL(ctx context.Context, name string, client *SequencerClient) error {
+	height, err := client.dtl.GetL1HighestSynced(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to l1dtl status: %s", err)
 	}
 
-	ticker := time.NewTimer(0)
-	defer ticker.Stop()
-
-	scrape := func(name string, client *SequencerClient) error {
-		if client.dtl == nil {
-			return nil
-		}
-
-		newctx, cancel := context.WithTimeout(basectx, time.Minute)
-		defer cancel()
+	m.logger.Debug("l1dtl", "name", name, "height", height)
 
-		height, err := client.dtl.GetL1HighestSynced(newctx)
-		if err != nil {
-			return fmt.Errorf("failed to l1dtl status: %s", err)
-		}
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
 
-		m.logger.Info("l1dtl", "name", name, "height", height)
-
-		client.mutex.Lock()
-		defer client.mutex.Unlock()
-
-		if t := float64(height) - client.lastHeights["l1dtl"]; t > 0 {
-			m.heights.With(prometheus.Labels{"svc_name": "l1dtl", "seq_name": name}).Add(t)
-			client.lastHeights["l1dtl"] += t
-		}
-
-		return nil
+	if t := float64(height) - client.lastHeights["l1dtl"]; t > 0 {
+		m.heights.With(prometheus.Labels{"svc_name": "l1dtl", "seq_name": name}).Add(t)
+		client.lastHeights["l1dtl"] += t
 	}
 
-	for {
-		select {
-		case <-basectx.Done():
-			return
-		case <-ticker.C:
-			var wg sync.WaitGroup
-			var start = time.Now()
-			for name, client := range m.clients {
-				wg.Add(1)
-				name, client := name, client
-				go func() {
-					if err := scrape(name, client); err != nil {
-						failureCounter.With(prometheus.Labels{"svc_name": fmt.Sprintf("seq-%s-l1dtl", name)}).Inc()
-						m.logger.Error("scrape l1dtl metrics", "seq", name, "err", err)
-					}
-					wg.Done()
-				}()
-			}
-			wg.Wait()
-			m.logger.Info("Done", "target", "l1dtl", "duration", time.Since(start))
-			ticker.Reset(scrapeInterval)
-		}
-	}
+	return nil
 }
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/dtl"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxStateRootBatches bounds the batches verified in a round, the rest are left to the next rounds
+const maxStateRootBatches = 10
+
+type stateRootMetric struct {
+	verified      *prometheus.CounterVec
+	mismatches    *prometheus.CounterVec
+	firstMismatch *prometheus.GaugeVec
+}
+
+func newStateRootMetric(reg prometheus.Registerer) *stateRootMetric {
+	m := &stateRootMetric{
+		verified: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "metis:sequencer:stateroot:verified",
+			Help: "Number of state roots committed to L1 and verified against l2geth.",
+		}, []string{"seq_name"}),
+		mismatches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "metis:sequencer:stateroot:mismatches",
+			Help: "Number of state roots committed to L1 that differ from the l2geth state root.",
+		}, []string{"seq_name"}),
+		firstMismatch: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "metis:sequencer:stateroot:first_mismatch_index",
+			Help: "Index of the first state root committed to L1 that differs from the l2geth state root.",
+		}, []string{"seq_name"}),
+	}
+	reg.MustRegister(m.verified, m.mismatches, m.firstMismatch)
+	return m
+}
+
+// scrapeStateRoot verifies the state root batches from the next one to the latest one, at most
+// maxStateRootBatches in a round, a batch is accounted only after all of its roots are checked
+func (m *SequencerMetric) scrapeStateRoot(ctx context.Context, name string, client *SequencerClient) error {
+	latest, err := client.dtl.GetLatestStateRootBatch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get latest state root batch: %s", err)
+	}
+
+	if latest.Batch == nil {
+		return nil
+	}
+
+	client.mutex.Lock()
+	if !client.stateRootStarted {
+		client.stateRootStarted = true
+		client.nextStateRootBatch = latest.Batch.Index
+	}
+	next := client.nextStateRootBatch
+	client.mutex.Unlock()
+
+	for index := next; index <= latest.Batch.Index && index < next+maxStateRootBatches; index++ {
+		res := latest
+		if index != latest.Batch.Index {
+			if res, err = client.dtl.GetStateRootBatchByIndex(ctx, index); err != nil {
+				return fmt.Errorf("failed to get state root batch %d: %s", index, err)
+			}
+			if res.Batch == nil {
+				return fmt.Errorf("state root batch %d is not found", index)
+			}
+		}
+
+		mismatches, err := checkStateRootBatch(ctx, client, res)
+		if errors.Is(err, ethereum.NotFound) {
+			m.logger.Warn("l2geth is behind the state root batch", "name", name, "batch", index, "err", err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		m.observeStateRootBatch(name, client, res, mismatches)
+	}
+	return nil
+}
+
+// stateRootMismatch is a committed state root which differs from the l2geth one
+type stateRootMismatch struct {
+	entry  *dtl.StateRootEntry
+	l2geth common.Hash
+}
+
+// checkStateRootBatch compares the roots of the batch with l2geth,
+// it returns ethereum.NotFound if l2geth doesn't have the block of a root yet
+func checkStateRootBatch(ctx context.Context, client *SequencerClient, res *dtl.StateRootBatchResponse) ([]stateRootMismatch, error) {
+	var mismatches []stateRootMismatch
+	for _, root := range res.StateRoots {
+		// the state root index i is the L2 block i+1
+		header, err := client.l2rpc.HeaderByNumber(ctx, new(big.Int).SetUint64(root.Index+1))
+		if errors.Is(err, ethereum.NotFound) {
+			return nil, fmt.Errorf("l2 block %d: %w", root.Index+1, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get l2 block %d: %s", root.Index+1, err)
+		}
+		if header.Root != root.Value {
+			mismatches = append(mismatches, stateRootMismatch{entry: root, l2geth: header.Root})
+		}
+	}
+	return mismatches, nil
+}
+
+// observeStateRootBatch accounts the checked batch and moves to the next one
+func (m *SequencerMetric) observeStateRootBatch(name string, client *SequencerClient, res *dtl.StateRootBatchResponse, mismatches []stateRootMismatch) {
+	labels := prometheus.Labels{"seq_name": name}
+	m.stateRoots.verified.With(labels).Add(float64(len(res.StateRoots)))
+	m.stateRoots.mismatches.With(labels).Add(float64(len(mismatches)))
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	for _, mismatch := range mismatches {
+		m.logger.Error("state root mismatch", "name", name, "batch", res.Batch.Index, "index", mismatch.entry.Index,
+			"committed", mismatch.entry.Value, "l2geth", mismatch.l2geth)
+
+		if !client.stateRootMismatched || mismatch.entry.Index < client.firstMismatchIndex {
+			client.stateRootMismatched = true
+			client.firstMismatchIndex = mismatch.entry.Index
+			m.stateRoots.firstMismatch.With(labels).Set(float64(mismatch.entry.Index))
+		}
+	}
+
+	m.logger.Debug("stateroot", "name", name, "batch", res.Batch.Index, "size", len(res.StateRoots))
+	client.nextStateRootBatch = res.Batch.Index + 1
+}
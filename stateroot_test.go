@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/dtl"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/ethrpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// l2Root is the state root of the l2 block on the fake l2geth
+func l2Root(number uint64) common.Hash {
+	return common.BigToHash(new(big.Int).SetUint64(number))
+}
+
+// newFakeL2Geth returns a json-rpc server which has the blocks up to the head
+func newFakeL2Geth(t *testing.T, head uint64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request: %s", err)
+			return
+		}
+		if req.Method != "eth_getBlockByNumber" {
+			t.Errorf("unexpected method %s", req.Method)
+		}
+
+		var number hexutil.Uint64
+		if err := json.Unmarshal(req.Params[0], &number); err != nil {
+			t.Errorf("invalid block number %s: %s", req.Params[0], err)
+		}
+		var result *types.Header
+		if uint64(number) <= head {
+			result = &types.Header{Number: new(big.Int).SetUint64(uint64(number)), Difficulty: common.Big0, Root: l2Root(uint64(number))}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+}
+
+// newFakeDTL returns a rest server of the batches which have 2 state roots each,
+// the roots of the mismatched indexes differ from the l2geth ones
+func newFakeDTL(batches uint64, mismatched map[uint64]bool) *httptest.Server {
+	batch := func(index uint64) *dtl.StateRootBatchResponse {
+		res := &dtl.StateRootBatchResponse{Batch: &dtl.StateRootBatchEntry{Index: index, Size: 2}}
+		for i := index * 2; i < index*2+2; i++ {
+			root := l2Root(i + 1)
+			if mismatched[i] {
+				root = common.HexToHash("0xbad")
+			}
+			res.StateRoots = append(res.StateRoots, &dtl.StateRootEntry{Index: i, BatchIndex: index, Value: root})
+		}
+		return res
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/batch/stateroot/latest", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(batch(batches - 1))
+	})
+	mux.HandleFunc("/batch/stateroot/index/{index}", func(w http.ResponseWriter, r *http.Request) {
+		var index uint64
+		if _, err := fmt.Sscan(r.PathValue("index"), &index); err != nil || index >= batches {
+			_ = json.NewEncoder(w).Encode(&dtl.StateRootBatchResponse{})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(batch(index))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestSequencerMetric_ScrapeStateRoot(t *testing.T) {
+	tests := []struct {
+		name       string
+		batches    uint64
+		mismatched map[uint64]bool
+		head       uint64
+		// fromGenesis verifies the batches from the first one instead of the latest one
+		fromGenesis       bool
+		wantVerified      float64
+		wantMismatches    float64
+		wantFirstMismatch float64
+		wantNext          uint64
+	}{
+		{
+			name:         "latest",
+			batches:      3,
+			head:         100,
+			wantVerified: 2,
+			wantNext:     3,
+		},
+		{
+			name:         "walk",
+			batches:      3,
+			head:         100,
+			fromGenesis:  true,
+			wantVerified: 6,
+			wantNext:     3,
+		},
+		{
+			name:              "mismatch",
+			batches:           3,
+			mismatched:        map[uint64]bool{4: true, 3: true},
+			head:              100,
+			fromGenesis:       true,
+			wantVerified:      6,
+			wantMismatches:    2,
+			wantFirstMismatch: 3,
+			wantNext:          3,
+		},
+		{
+			// l2geth has the roots 0 to 2, the batch 1 of the roots 2 and 3 is checked again in the next round
+			name:         "behind",
+			batches:      3,
+			mismatched:   map[uint64]bool{2: true},
+			head:         3,
+			fromGenesis:  true,
+			wantVerified: 2,
+			wantNext:     1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l2geth := newFakeL2Geth(t, tt.head)
+			defer l2geth.Close()
+			rest := newFakeDTL(tt.batches, tt.mismatched)
+			defer rest.Close()
+
+			client := newSequencerClient(nil)
+			client.l2rpc = ethrpc.New(l2geth.URL)
+			defer client.l2rpc.Close()
+			var err error
+			if client.dtl, err = dtl.NewClient(rest.URL, nil); err != nil {
+				t.Fatal(err)
+			}
+			client.stateRootStarted = tt.fromGenesis
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			m := newSequencerMetric(prometheus.NewRegistry(), map[string]*SequencerClient{"seq": client}, nil, logger)
+
+			// the second round doesn't account the batches again
+			for i := 0; i < 2; i++ {
+				if err := m.scrapeStateRoot(context.Background(), "seq", client); err != nil {
+					t.Fatalf("scrapeStateRoot() error = %v", err)
+				}
+			}
+
+			labels := prometheus.Labels{"seq_name": "seq"}
+			if got := testutil.ToFloat64(m.stateRoots.verified.With(labels)); got != tt.wantVerified {
+				t.Errorf("verified = %v, want %v", got, tt.wantVerified)
+			}
+			if got := testutil.ToFloat64(m.stateRoots.mismatches.With(labels)); got != tt.wantMismatches {
+				t.Errorf("mismatches = %v, want %v", got, tt.wantMismatches)
+			}
+			if got := testutil.ToFloat64(m.stateRoots.firstMismatch.With(labels)); got != tt.wantFirstMismatch {
+				t.Errorf("first mismatch index = %v, want %v", got, tt.wantFirstMismatch)
+			}
+			if client.nextStateRootBatch != tt.wantNext {
+				t.Errorf("next batch = %d, want %d", client.nextStateRootBatch, tt.wantNext)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/metis-devops/metis-sequencer-exporter/internal/alert"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func validateCommand(args []string) int {
+	var confPath string
+
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	fs.StringVar(&confPath, "config", "config.yaml", "config path")
+	_ = fs.Parse(args)
+
+	conf, err := config.Parse(confPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", confPath, err)
+		return 1
+	}
+
+	// the alert templates are only checked when the engine is built
+	if conf.Alerting != nil {
+		if _, err := alert.NewEngine(conf.Alerting, prometheus.NewRegistry()); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: alerting: %s\n", confPath, err)
+			return 1
+		}
+	}
+
+	fmt.Printf("%s: OK\n", confPath)
+	return 0
+}
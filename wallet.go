@@ -2,53 +2,97 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
+	"maps"
+	"math/big"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/collector"
 	"github.com/metis-devops/metis-sequencer-exporter/internal/config"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/ethrpc"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/logging"
 	"github.com/metis-devops/metis-sequencer-exporter/internal/themis"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/transport"
 	"github.com/metis-devops/metis-sequencer-exporter/internal/utils"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// mpcRetryInterval is the first interval to retry the mpc addresses which are failed to resolve,
+// it's doubled after every retry up to mpcMaxRetryInterval
+const (
+	mpcRetryInterval    = 30 * time.Second
+	mpcMaxRetryInterval = 10 * time.Minute
+)
+
+// rpcHealthCheckInterval is the interval to health check the rpc urls of the wallet services
+const rpcHealthCheckInterval = 15 * time.Second
+
 type WalletMetric struct {
-	l1rpc     *ethclient.Client
-	l2rpc     *ethclient.Client
+	l1rpc  *ethrpc.Client
+	l2rpc  *ethrpc.Client
+	themis *themis.Client
+
+	// the mpc wallets are added in the background, so the wallets are guarded by the mutex
 	l1Wallets map[string]common.Address
 	l2Wallets map[string]common.Address
 
-	balance *prometheus.GaugeVec
-	nonce   *prometheus.CounterVec
+	balance     *prometheus.GaugeVec
+	nonce       *prometheus.CounterVec
+	spendRate   *prometheus.GaugeVec
+	timeToEmpty *prometheus.GaugeVec
+	nonceGap    *prometheus.GaugeVec
+	gapDuration *prometheus.GaugeVec
+	nonceAge    *prometheus.GaugeVec
+	txs         *walletTxsMetric
+	rollup      *rollupMetric
+
+	mutex      sync.Mutex
+	nonceMap   map[string]float64
+	burnRates  map[string]*burnRate
+	gapSince   map[string]time.Time
+	advancedAt map[string]time.Time
+	burnWindow time.Duration
+	scrape     *config.WalletScrape
+	l1Quorum   int
+	logger     *slog.Logger
+}
 
-	mutex    sync.Mutex
-	nonceMap map[string]float64
-	logger   *slog.Logger
+// newFailoverRPC returns the rpc client of the service which fails over between the urls
+func newFailoverRPC(logger *slog.Logger, pool *transport.Pool, metrics *ethrpc.Metrics, svc string, urls config.URLs, conf *config.HTTPClient) (*ethrpc.Client, error) {
+	var endpoints []ethrpc.Endpoint
+	for _, url := range urls {
+		logger.Info("connect to "+svc, "url", utils.RedactURL(url))
+		opts, err := pool.RPCOptions(url, conf)
+		if err != nil {
+			return nil, fmt.Errorf("connect to %s %s: %s", svc, utils.RedactURL(url), err)
+		}
+		endpoints = append(endpoints, ethrpc.Endpoint{URL: url, Options: opts, Label: pool.Label(url)})
+	}
+	return ethrpc.NewFailover("wallet_"+svc, endpoints, metrics), nil
 }
 
-func NewWalletMetric(basectx context.Context, reg prometheus.Registerer, conf *config.Config) (*WalletMetric, error) {
+// NewWalletMetric doesn't connect to the services, and the mpc addresses are resolved in the background,
+// so the unreachable ones fail the scrapes instead of the startup
+func NewWalletMetric(reg prometheus.Registerer, network string, conf *config.Network, pool *transport.Pool, burnWindow time.Duration) (*WalletMetric, error) {
 	if conf.Wallet == nil {
 		return nil, nil
 	}
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, nil)).With("module", "wallet")
-	ctx, cancel := context.WithTimeout(basectx, time.Minute)
-	defer cancel()
+	logger := logging.New("wallet").With("network", network)
 
-	logger.Info("connect to l2geth", "url", conf.Wallet.L2Geth)
-	l2rpc, err := ethclient.DialContext(ctx, conf.Wallet.L2Geth)
+	rpcMetrics := ethrpc.NewMetrics(reg)
+	l2rpc, err := newFailoverRPC(logger, pool, rpcMetrics, "l2geth", conf.Wallet.L2Geth, conf.Wallet.Clients.Service("l2geth"))
 	if err != nil {
-		return nil, fmt.Errorf("connect to l2geth %s", conf.Wallet.L2Geth)
+		return nil, err
 	}
-
-	logger.Info("connect to l1geth", "url", conf.Wallet.L1Geth)
-	l1rpc, err := ethclient.DialContext(ctx, conf.Wallet.L1Geth)
+	l1rpc, err := newFailoverRPC(logger, pool, rpcMetrics, "l1geth", conf.Wallet.L1Geth, conf.Wallet.Clients.Service("l1geth"))
 	if err != nil {
-		return nil, fmt.Errorf("connect to l1geth %s", conf.Wallet.L1Geth)
+		return nil, err
 	}
 
 	l1Wallets := make(map[string]common.Address)
@@ -63,100 +107,287 @@ func NewWalletMetric(basectx context.Context, reg prometheus.Registerer, conf *c
 		logger.Info("Add custom L2 wallet", "name", name, "wallet", wallet)
 	}
 
+	var pos *themis.Client
 	if conf.Wallet.Themis == "" {
 		logger.Warn("mpc wallet metric is disabled")
-		if len(conf.Wallet.Wallets) == 0 {
+		if len(conf.Wallet.Wallets) == 0 && conf.Wallet.Rollup == nil {
 			return nil, nil
 		}
 	} else {
-		logger.Info("connect to themis", "url", conf.Wallet.Themis)
-		pos, err := themis.NewClient(conf.Wallet.Themis)
+		logger.Info("connect to themis", "url", utils.RedactURL(conf.Wallet.Themis))
+		httpClient, err := pool.HTTPClient(conf.Wallet.Themis, conf.Wallet.Clients.Service("themis"))
+		if err == nil {
+			pos, err = themis.NewClient(conf.Wallet.Themis, httpClient)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("connect to themis %s", conf.Wallet.L1Geth)
+			return nil, fmt.Errorf("connect to themis %s: %s", utils.RedactURL(conf.Wallet.Themis), err)
 		}
-		for i := themis.CommonMpcAddr; i <= themis.BlobSubmitMpcAddr; i++ {
-			res, err := pos.LatestMpcInfo(ctx, i)
-			if err != nil && i != themis.BlobSubmitMpcAddr {
-				return nil, fmt.Errorf("get mpc address %s: %w", i, err)
-			}
+	}
 
-			if _, ok := l1Wallets[i.String()]; ok {
-				return nil, fmt.Errorf("custom L1 wallet is duplicated with mpc address %s", i)
-			}
+	balance := newWalletBalanceVec()
 
-			if i == themis.CommonMpcAddr {
-				if _, ok := l2Wallets[i.String()]; ok {
-					return nil, fmt.Errorf("custom L2 wallet is duplicated with mpc address %s", i)
-				}
-				l1Wallets[i.String()] = res.Address
-				l2Wallets[i.String()] = res.Address
-			} else {
-				l1Wallets[i.String()] = res.Address
-			}
-		}
-	}
+	nonce := newWalletNonceVec()
 
-	balance := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "metis:sequencer:wallet:balance",
-		Help: "Balance of mpc and custom addresses from config",
+	spendRate := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "metis:sequencer:wallet:spend_rate",
+		Help: "Spent amount per hour of mpc and custom addresses within the burn rate window, deposits excluded",
 	}, []string{"chain", "addr", "alias"})
 
-	nonce := prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "metis:sequencer:wallet:nonce",
-		Help: "Nonce of mpc and custom addresses from config",
+	timeToEmpty := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "metis:sequencer:wallet:time_to_empty",
+		Help: "Projected seconds until the balance of mpc and custom addresses runs out",
+	}, []string{"chain", "addr", "alias"})
+
+	nonceGap := newWalletNonceGapVec()
+
+	gapDuration := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "metis:sequencer:wallet:nonce_gap_duration",
+		Help: "Seconds the non-zero nonce gap of mpc and custom addresses has persisted, it restarts from zero when the exporter restarts",
+	}, []string{"chain", "addr", "alias"})
+
+	nonceAge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "metis:sequencer:wallet:nonce_age",
+		Help: "Seconds since the latest nonce of mpc and custom addresses last advanced, it restarts from zero when the exporter restarts",
 	}, []string{"chain", "addr", "alias"})
 
-	reg.MustRegister(balance, nonce)
+	reg.MustRegister(balance, nonce, spendRate, timeToEmpty, nonceGap, gapDuration, nonceAge)
+
+	var rollups *rollupMetric
+	if conf.Wallet.Rollup != nil {
+		logger.Info("Add rollup contracts", "ctc", conf.Wallet.Rollup.CTC, "scc", conf.Wallet.Rollup.SCC)
+		rollups = newRollupMetric(reg, conf.Wallet.Rollup, l1rpc)
+	}
 
 	return &WalletMetric{
-		l1rpc:     l1rpc,
-		l2rpc:     l2rpc,
-		l1Wallets: l1Wallets,
-		l2Wallets: l2Wallets,
-		balance:   balance,
-		nonce:     nonce,
-		nonceMap:  make(map[string]float64),
-		logger:    logger,
+		l1rpc:       l1rpc,
+		l2rpc:       l2rpc,
+		themis:      pos,
+		l1Wallets:   l1Wallets,
+		l2Wallets:   l2Wallets,
+		balance:     balance,
+		nonce:       nonce,
+		spendRate:   spendRate,
+		timeToEmpty: timeToEmpty,
+		nonceGap:    nonceGap,
+		gapDuration: gapDuration,
+		nonceAge:    nonceAge,
+		txs:         newWalletTxsMetric(reg, l1Wallets),
+		rollup:      rollups,
+		nonceMap:    make(map[string]float64),
+		burnRates:   make(map[string]*burnRate),
+		burnWindow:  burnWindow,
+		scrape:      conf.Wallet.Scrape,
+		l1Quorum:    conf.Wallet.L1Quorum,
+		gapSince:    make(map[string]time.Time),
+		advancedAt:  make(map[string]time.Time),
+		logger:      logger,
 	}, nil
 }
 
-func (m *WalletMetric) Scrape(basectx context.Context, failureCounter *prometheus.CounterVec, scrapeInterval time.Duration) {
+// nonceGap returns the pending transactions of the address, the nonces should be read from the same url
+func nonceGap(latest, pending uint64) uint64 {
+	if pending > latest {
+		return pending - latest
+	}
+	return 0
+}
+
+// observeNonce should be called with the mutex held and before updating the nonceMap.
+// The nonce age and the gap duration are kept in memory, so they restart from zero when the exporter restarts.
+func (m *WalletMetric) observeNonce(now time.Time, key string, labels prometheus.Labels, nonce, gap uint64) {
+	if last, ok := m.nonceMap[key]; !ok || float64(nonce) > last {
+		m.advancedAt[key] = now
+	}
+	m.nonceAge.With(labels).Set(now.Sub(m.advancedAt[key]).Seconds())
+
+	m.nonceGap.With(labels).Set(float64(gap))
+
+	if gap == 0 {
+		delete(m.gapSince, key)
+		m.gapDuration.With(labels).Set(0)
+		return
+	}
+	since, ok := m.gapSince[key]
+	if !ok {
+		since = now
+		m.gapSince[key] = now
+	}
+	m.gapDuration.With(labels).Set(now.Sub(since).Seconds())
+}
+
+// observeBalance should be called with the mutex held
+func (m *WalletMetric) observeBalance(key string, labels prometheus.Labels, balance float64) {
+	br, ok := m.burnRates[key]
+	if !ok {
+		br = newBurnRate(m.burnWindow)
+		m.burnRates[key] = br
+	}
+	br.Observe(time.Now(), balance)
+
+	if rate, ok := br.PerHour(); ok {
+		m.spendRate.With(labels).Set(rate)
+	}
+	if seconds, ok := br.SecondsToEmpty(); ok {
+		m.timeToEmpty.With(labels).Set(seconds)
+	}
+}
+
+// Probes returns the probes of the wallet services, the schedule of every service is resolved from the config
+func (m *WalletMetric) Probes(scrapeInterval time.Duration) []collector.Probe {
 	if m == nil {
 		slog.Warn("wallet metric is disabled")
+		return nil
+	}
+
+	target := func(svc, name string) []collector.Target {
+		return []collector.Target{{Name: name, Options: collector.NewOptions(scrapeInterval, m.scrape.Service(svc))}}
+	}
+	probes := []collector.Probe{
+		collector.NewProbe("wallet/l2geth", target("l2geth", "metis_balance"), m.scrapeL2),
+		collector.NewProbe("wallet/l1geth", target("l1geth", "eth_balance"), m.scrapeL1),
+		collector.NewProbe("wallet/txs", target("txs", "eth_txs"), m.scrapeL1Txs),
+	}
+
+	if m.rollup == nil {
+		m.logger.Warn("rollup metric is disabled")
+		return probes
+	}
+	var rollups []collector.Target
+	for _, name := range slices.Sorted(maps.Keys(m.rollup.chains)) {
+		rollups = append(rollups, collector.Target{
+			Name:     fmt.Sprintf("rollup_%s", name),
+			Instance: name,
+			Options:  collector.NewOptions(scrapeInterval, m.scrape.Service("rollup")),
+		})
+	}
+	return append(probes, collector.NewProbe("wallet/rollup", rollups, m.scrapeRollup))
+}
+
+// Run resolves the mpc addresses and health checks the rpc urls until the context is done
+func (m *WalletMetric) Run(basectx context.Context) {
+	if m == nil {
 		return
 	}
-	go m.scrapeL2(basectx, failureCounter, scrapeInterval)
-	go m.scrapeL1(basectx, failureCounter, scrapeInterval)
+	go m.l1rpc.Run(basectx, rpcHealthCheckInterval)
+	go m.l2rpc.Run(basectx, rpcHealthCheckInterval)
+	m.resolveMpcAddrs(basectx)
 }
 
-func (m *WalletMetric) scrapeL2(basectx context.Context, failureCounter *prometheus.CounterVec, scrapeInterval time.Duration) {
+// resolveMpcAddrs adds the mpc wallets from themis, the failed ones are retried with backoff until all are resolved,
+// the blob submitter is optional, so it's not retried if themis doesn't have it
+func (m *WalletMetric) resolveMpcAddrs(basectx context.Context) {
+	if m.themis == nil {
+		return
+	}
+
+	var pending []themis.MpcAddrType
+	for i := themis.CommonMpcAddr; i <= themis.BlobSubmitMpcAddr; i++ {
+		pending = append(pending, i)
+	}
+
 	ticker := time.NewTimer(0)
 	defer ticker.Stop()
 
-	scrape := func(name string, addr common.Address) error {
+	retry := mpcRetryInterval
+	warned := make(map[themis.MpcAddrType]bool)
+	for len(pending) > 0 {
+		select {
+		case <-basectx.Done():
+			return
+		case <-ticker.C:
+			var failed []themis.MpcAddrType
+			for _, i := range pending {
+				err := m.resolveMpcAddr(basectx, i)
+				switch {
+				case err == nil:
+				case i == themis.BlobSubmitMpcAddr && errors.Is(err, themis.ErrNotFound):
+					m.logger.Info("no blob submit mpc address", "type", i, "err", err)
+				default:
+					// only the first failure is a warning, the retries of the same address are logged at debug
+					level := slog.LevelWarn
+					if warned[i] {
+						level = slog.LevelDebug
+					}
+					warned[i] = true
+					m.logger.Log(basectx, level, "resolve mpc address", "type", i, "retry", retry, "err", err)
+					failed = append(failed, i)
+				}
+			}
+			pending = failed
+			ticker.Reset(retry)
+			retry = min(retry*2, mpcMaxRetryInterval)
+		}
+	}
+}
+
+func (m *WalletMetric) resolveMpcAddr(basectx context.Context, addrType themis.MpcAddrType) error {
+	ctx, cancel := context.WithTimeout(basectx, time.Minute)
+	defer cancel()
+
+	res, err := m.themis.LatestMpcInfo(ctx, addrType)
+	if err != nil {
+		return err
+	}
+
+	m.logger.Info("Add mpc wallet", "name", addrType, "wallet", res.Address)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.l1Wallets[addrType.String()] = res.Address
+	if addrType == themis.CommonMpcAddr {
+		m.l2Wallets[addrType.String()] = res.Address
+	}
+	m.txs.addSender(addrType.String(), res.Address)
+	return nil
+}
+
+// copyWallets returns a copy of the wallets which can be iterated without the mutex
+func (m *WalletMetric) copyWallets(wallets map[string]common.Address) map[string]common.Address {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return maps.Clone(wallets)
+}
+
+// scrapeWallets scrapes the wallets concurrently, it's skipped if there is no wallet yet
+func (m *WalletMetric) scrapeWallets(wallets map[string]common.Address, scrape func(alias string, addr common.Address) error) error {
+	wallets = m.copyWallets(wallets)
+	if len(wallets) == 0 {
+		return collector.ErrSkipped
+	}
+	return collector.Parallel(slices.Sorted(maps.Keys(wallets)), func(alias string) error {
+		return scrape(alias, wallets[alias])
+	})
+}
+
+func (m *WalletMetric) scrapeL2(ctx context.Context, _ collector.Target) error {
+	return m.scrapeWallets(m.l2Wallets, func(name string, addr common.Address) error {
 		labels := prometheus.Labels{"chain": "metis", "addr": addr.Hex(), "alias": name}
 		nonceKey := fmt.Sprintf("metis:%s", name)
 
-		newctx, cancel := context.WithTimeout(basectx, time.Minute)
-		defer cancel()
-
-		wei, err := m.l2rpc.BalanceAt(newctx, addr, nil)
+		wei, err := m.l2rpc.BalanceAt(ctx, addr, nil)
 		if err != nil {
 			return fmt.Errorf("failed to get balance: %s", err)
 		}
 		balance := utils.ToEther(wei)
 
-		nonce, err := m.l2rpc.NonceAt(newctx, addr, nil)
+		nonce, err := m.l2rpc.NonceAt(ctx, addr, nil)
 		if err != nil {
 			return fmt.Errorf("failed to get nonce: %s", err)
 		}
 
-		m.logger.Info("wallet", "chain", "metis", "alias", name, "addr", addr, "balance", balance, "nonce", nonce)
+		pending, err := m.l2rpc.PendingNonceAt(ctx, addr)
+		if err != nil {
+			return fmt.Errorf("failed to get pending nonce: %s", err)
+		}
+
+		m.logger.Debug("wallet", "chain", "metis", "alias", name, "addr", addr, "balance", balance, "nonce", nonce, "pending", pending)
 
 		m.balance.With(labels).Set(balance)
 
 		m.mutex.Lock()
 		defer m.mutex.Unlock()
+		m.observeBalance(nonceKey, labels, balance)
+		m.observeNonce(time.Now(), nonceKey, labels, nonce, nonceGap(nonce, pending))
 		if v, ok := m.nonceMap[nonceKey]; !ok && nonce == 0 {
 			m.nonce.With(labels).Add(0)
 			m.nonceMap[nonceKey] = 0
@@ -165,61 +396,51 @@ func (m *WalletMetric) scrapeL2(basectx context.Context, failureCounter *prometh
 			m.nonceMap[nonceKey] += t
 		}
 		return nil
-	}
-
-	for {
-		select {
-		case <-basectx.Done():
-			return
-		case <-ticker.C:
-			var wg sync.WaitGroup
-			var start = time.Now()
-			for name, addr := range m.l2Wallets {
-				wg.Add(1)
-				name, addr := name, addr
-				go func() {
-					if err := scrape(name, addr); err != nil {
-						failureCounter.With(prometheus.Labels{"svc_name": "metis_balance"}).Inc()
-						m.logger.Error("scrape metis wallet metrics", "addr", name, "err", err)
-					}
-					wg.Done()
-				}()
-			}
-			wg.Wait()
-			m.logger.Info("Done", "target", "metis_wallet", "duration", time.Since(start))
-			ticker.Reset(scrapeInterval)
-		}
-	}
+	})
 }
 
-func (m *WalletMetric) scrapeL1(basectx context.Context, failureCounter *prometheus.CounterVec, scrapeInterval time.Duration) {
-	ticker := time.NewTimer(0)
-	defer ticker.Stop()
+func (m *WalletMetric) scrapeL1(ctx context.Context, _ collector.Target) error {
+	number, err := m.l1QuorumBlock(ctx)
+	if err != nil {
+		return err
+	}
 
-	scrape := func(name string, addr common.Address) error {
+	return m.scrapeWallets(m.l1Wallets, func(name string, addr common.Address) error {
 		labels := prometheus.Labels{"chain": "eth", "addr": addr.Hex(), "alias": name}
 		nonceKey := fmt.Sprintf("eth:%s", name)
 
-		newctx, cancel := context.WithTimeout(basectx, time.Minute)
-		defer cancel()
-
-		wei, err := m.l1rpc.BalanceAt(newctx, addr, nil)
+		wei, err := m.l1BalanceAt(ctx, addr, number)
 		if err != nil {
 			return fmt.Errorf("failed to get balance: %s", err)
 		}
 		balance := utils.ToEther(wei)
 
-		nonce, err := m.l1rpc.NonceAt(newctx, addr, nil)
+		nonce, err := m.l1NonceAt(ctx, addr, number)
 		if err != nil {
 			return fmt.Errorf("failed to get nonce: %s", err)
 		}
 
-		m.logger.Info("wallet", "chain", "eth", "alias", name, "addr", addr, "balance", balance, "nonce", nonce)
+		pending, err := m.l1rpc.PendingNonceAt(ctx, addr)
+		if err != nil {
+			return fmt.Errorf("failed to get pending nonce: %s", err)
+		}
+
+		// the pending nonce can't be pinned, so the gap is the difference from the latest nonce of the same url
+		latest := nonce
+		if number != nil {
+			if latest, err = m.l1rpc.NonceAt(ctx, addr, nil); err != nil {
+				return fmt.Errorf("failed to get latest nonce: %s", err)
+			}
+		}
+
+		m.logger.Debug("wallet", "chain", "eth", "alias", name, "addr", addr, "balance", balance, "nonce", nonce, "latest", latest, "pending", pending)
 
 		m.balance.With(labels).Set(balance)
 
 		m.mutex.Lock()
 		defer m.mutex.Unlock()
+		m.observeBalance(nonceKey, labels, balance)
+		m.observeNonce(time.Now(), nonceKey, labels, nonce, nonceGap(latest, pending))
 		if v, ok := m.nonceMap[nonceKey]; !ok && nonce == 0 {
 			m.nonce.With(labels).Add(0)
 			m.nonceMap[nonceKey] = 0
@@ -228,29 +449,54 @@ func (m *WalletMetric) scrapeL1(basectx context.Context, failureCounter *prometh
 			m.nonceMap[nonceKey] += t
 		}
 		return nil
+	})
+}
+
+// l1QuorumBlock returns the l1 head which the balances and the nonces are pinned to in the quorum mode,
+// otherwise nil for the latest block of the active url
+func (m *WalletMetric) l1QuorumBlock(ctx context.Context) (*big.Int, error) {
+	if m.l1Quorum == 0 {
+		return nil, nil
 	}
 
-	for {
-		select {
-		case <-basectx.Done():
-			return
-		case <-ticker.C:
-			var wg sync.WaitGroup
-			var start = time.Now()
-			for alias, addr := range m.l1Wallets {
-				wg.Add(1)
-				alias, addr := alias, addr
-				go func() {
-					if err := scrape(alias, addr); err != nil {
-						failureCounter.With(prometheus.Labels{"svc_name": "eth_balance"}).Inc()
-						m.logger.Error("scrape eth wallet metrics", "alias", alias, "err", err)
-					}
-					wg.Done()
-				}()
-			}
-			wg.Wait()
-			m.logger.Info("Done", "target", "eth_wallet", "duration", time.Since(start))
-			ticker.Reset(scrapeInterval)
-		}
+	head, err := m.l1rpc.QuorumHead(ctx, m.l1Quorum)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get l1 quorum height: %s", err)
+	}
+	return new(big.Int).SetUint64(head), nil
+}
+
+func (m *WalletMetric) l1BalanceAt(ctx context.Context, addr common.Address, number *big.Int) (*big.Int, error) {
+	if m.l1Quorum == 0 {
+		return m.l1rpc.BalanceAt(ctx, addr, number)
+	}
+	return m.l1rpc.QuorumBalanceAt(ctx, m.l1Quorum, addr, number)
+}
+
+func (m *WalletMetric) l1NonceAt(ctx context.Context, addr common.Address, number *big.Int) (uint64, error) {
+	if m.l1Quorum == 0 {
+		return m.l1rpc.NonceAt(ctx, addr, number)
 	}
+	return m.l1rpc.QuorumNonceAt(ctx, m.l1Quorum, addr, number)
+}
+
+func newWalletBalanceVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "metis:sequencer:wallet:balance",
+		Help: "Balance of mpc and custom addresses from config",
+	}, []string{"chain", "addr", "alias"})
+}
+
+func newWalletNonceVec() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "metis:sequencer:wallet:nonce",
+		Help: "Nonce of mpc and custom addresses from config",
+	}, []string{"chain", "addr", "alias"})
+}
+
+func newWalletNonceGapVec() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "metis:sequencer:wallet:nonce_gap",
+		Help: "Difference between the pending and latest nonce of mpc and custom addresses",
+	}, []string{"chain", "addr", "alias"})
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNonceGap(t *testing.T) {
+	tests := []struct {
+		latest, pending uint64
+		want            uint64
+	}{
+		{latest: 10, pending: 10, want: 0},
+		{latest: 10, pending: 13, want: 3},
+		// the pending nonce of a lagging node can be behind the latest one
+		{latest: 10, pending: 9, want: 0},
+	}
+	for _, tt := range tests {
+		if got := nonceGap(tt.latest, tt.pending); got != tt.want {
+			t.Errorf("nonceGap(%d, %d) = %d, want %d", tt.latest, tt.pending, got, tt.want)
+		}
+	}
+}
+
+func TestWalletMetric_ObserveNonce(t *testing.T) {
+	m := &WalletMetric{
+		nonceGap: newWalletNonceGapVec(),
+		gapDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "metis:sequencer:wallet:nonce_gap_duration",
+		}, []string{"chain", "addr", "alias"}),
+		nonceAge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "metis:sequencer:wallet:nonce_age",
+		}, []string{"chain", "addr", "alias"}),
+		nonceMap:   make(map[string]float64),
+		gapSince:   make(map[string]time.Time),
+		advancedAt: make(map[string]time.Time),
+	}
+	labels := prometheus.Labels{"chain": "eth", "addr": "0x01", "alias": "CommonMpcAddr"}
+	key := "eth:CommonMpcAddr"
+
+	start := time.Unix(1700000000, 0)
+	steps := []struct {
+		offset          time.Duration
+		nonce, gap      uint64
+		wantGap         float64
+		wantGapDuration float64
+		wantAge         float64
+	}{
+		// the first observation starts the age from zero, as after a restart
+		{offset: 0, nonce: 10},
+		{offset: time.Minute, nonce: 10, wantAge: 60},
+		{offset: 2 * time.Minute, nonce: 10, gap: 2, wantGap: 2, wantAge: 120},
+		{offset: 5 * time.Minute, nonce: 10, gap: 3, wantGap: 3, wantGapDuration: 180, wantAge: 300},
+		// the advanced nonce resets the age but not the persisting gap
+		{offset: 6 * time.Minute, nonce: 11, gap: 1, wantGap: 1, wantGapDuration: 240},
+		{offset: 7 * time.Minute, nonce: 11, wantAge: 60},
+		// a new gap starts its duration from zero
+		{offset: 8 * time.Minute, nonce: 11, gap: 1, wantGap: 1, wantAge: 120},
+		{offset: 9 * time.Minute, nonce: 12, gap: 1, wantGap: 1, wantGapDuration: 60},
+	}
+	for _, step := range steps {
+		m.observeNonce(start.Add(step.offset), key, labels, step.nonce, step.gap)
+		m.nonceMap[key] = float64(step.nonce)
+
+		if got := testutil.ToFloat64(m.nonceGap.With(labels)); got != step.wantGap {
+			t.Errorf("nonce_gap at %s = %v, want %v", step.offset, got, step.wantGap)
+		}
+		if got := testutil.ToFloat64(m.gapDuration.With(labels)); got != step.wantGapDuration {
+			t.Errorf("nonce_gap_duration at %s = %v, want %v", step.offset, got, step.wantGapDuration)
+		}
+		if got := testutil.ToFloat64(m.nonceAge.With(labels)); got != step.wantAge {
+			t.Errorf("nonce_age at %s = %v, want %v", step.offset, got, step.wantAge)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/collector"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/themis"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxBlocksPerRound limits the blocks to process in a single round,
+// the rest are processed in the following rounds
+const maxBlocksPerRound = 64
+
+type walletTxsMetric struct {
+	sent     *prometheus.CounterVec
+	fees     *prometheus.CounterVec
+	reverted *prometheus.CounterVec
+
+	blobTxs     *prometheus.CounterVec
+	blobs       *prometheus.CounterVec
+	blobGasUsed *prometheus.CounterVec
+	blobFees    *prometheus.CounterVec
+	blobAge     *prometheus.GaugeVec
+
+	senders    map[common.Address]string
+	lastBlock  uint64
+	lastBlobAt map[string]time.Time
+}
+
+func newWalletTxsMetric(reg prometheus.Registerer, wallets map[string]common.Address) *walletTxsMetric {
+	sent := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "metis:sequencer:wallet:tx_sent",
+		Help: "Number of L1 transactions sent by mpc and custom addresses",
+	}, []string{"chain", "addr", "alias"})
+
+	fees := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "metis:sequencer:wallet:tx_fees",
+		Help: "Gas fees in ETH paid by mpc and custom addresses, blob fees included",
+	}, []string{"chain", "addr", "alias"})
+
+	reverted := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "metis:sequencer:wallet:tx_reverted",
+		Help: "Number of reverted L1 transactions sent by mpc and custom addresses",
+	}, []string{"chain", "addr", "alias"})
+
+	blobTxs := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "metis:sequencer:wallet:blob_tx_sent",
+		Help: "Number of EIP-4844 blob transactions sent by mpc and custom addresses",
+	}, []string{"chain", "addr", "alias"})
+
+	blobs := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "metis:sequencer:wallet:blobs",
+		Help: "Number of blobs submitted by mpc and custom addresses",
+	}, []string{"chain", "addr", "alias"})
+
+	blobGasUsed := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "metis:sequencer:wallet:blob_gas_used",
+		Help: "Blob gas used by mpc and custom addresses",
+	}, []string{"chain", "addr", "alias"})
+
+	blobFees := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "metis:sequencer:wallet:blob_fees",
+		Help: "Blob fees in ETH paid by mpc and custom addresses",
+	}, []string{"chain", "addr", "alias"})
+
+	blobAge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "metis:sequencer:wallet:blob_age",
+		Help: "Seconds since the last blob submission of mpc and custom addresses",
+	}, []string{"chain", "addr", "alias"})
+
+	reg.MustRegister(sent, fees, reverted, blobTxs, blobs, blobGasUsed, blobFees, blobAge)
+
+	t := &walletTxsMetric{
+		sent:        sent,
+		fees:        fees,
+		reverted:    reverted,
+		blobTxs:     blobTxs,
+		blobs:       blobs,
+		blobGasUsed: blobGasUsed,
+		blobFees:    blobFees,
+		blobAge:     blobAge,
+		senders:     make(map[common.Address]string),
+		lastBlobAt:  make(map[string]time.Time),
+	}
+	for alias, addr := range wallets {
+		t.addSender(alias, addr)
+	}
+	return t
+}
+
+// addSender tracks the transactions sent by the address, it should be called with the wallet mutex held
+func (t *walletTxsMetric) addSender(alias string, addr common.Address) {
+	t.senders[addr] = alias
+	labels := prometheus.Labels{"chain": "eth", "addr": addr.Hex(), "alias": alias}
+	t.sent.With(labels).Add(0)
+	t.fees.With(labels).Add(0)
+	t.reverted.With(labels).Add(0)
+
+	// the blob age of the blob submitter is unknown until its first submission is seen,
+	// so a stalled submitter is caught by the missing series rather than reset by a restart
+	if alias == themis.BlobSubmitMpcAddr.String() {
+		t.blobTxs.With(labels).Add(0)
+		t.blobs.With(labels).Add(0)
+		t.blobGasUsed.With(labels).Add(0)
+		t.blobFees.With(labels).Add(0)
+	}
+}
+
+// updateBlobAge sets the blob age gauge of the addresses which have submitted blobs,
+// it should be called with the wallet mutex held
+func (t *walletTxsMetric) updateBlobAge() {
+	for addr, alias := range t.senders {
+		if at, ok := t.lastBlobAt[alias]; ok {
+			labels := prometheus.Labels{"chain": "eth", "addr": addr.Hex(), "alias": alias}
+			t.blobAge.With(labels).Set(time.Since(at).Seconds())
+		}
+	}
+}
+
+func (m *WalletMetric) scrapeL1Txs(ctx context.Context, _ collector.Target) error {
+	var head uint64
+	var err error
+	if m.l1Quorum > 0 {
+		head, err = m.l1rpc.QuorumHead(ctx, m.l1Quorum)
+	} else {
+		head, err = m.l1rpc.BlockNumber(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get l1 height: %s", err)
+	}
+
+	// start from the current head, the history is not accounted
+	if m.txs.lastBlock == 0 {
+		m.txs.lastBlock = head
+		return nil
+	}
+
+	end := min(head, m.txs.lastBlock+maxBlocksPerRound)
+	for number := m.txs.lastBlock + 1; number <= end; number++ {
+		txs, err := m.fetchL1Block(ctx, number)
+		if err != nil {
+			return fmt.Errorf("failed to process l1 block %d: %s", number, err)
+		}
+		// the block is accounted only if all of its transactions are fetched,
+		// otherwise it's fetched again in the next round
+		for _, tx := range txs {
+			m.observeL1Tx(tx)
+		}
+		m.txs.lastBlock = number
+	}
+	m.logger.Debug("eth_txs", "block", m.txs.lastBlock)
+
+	m.mutex.Lock()
+	m.txs.updateBlobAge()
+	m.mutex.Unlock()
+	return nil
+}
+
+// l1Tx is a transaction sent by a tracked address
+type l1Tx struct {
+	alias     string
+	from      common.Address
+	tx        *types.Transaction
+	receipt   *types.Receipt
+	blockTime time.Time
+}
+
+// fetchL1Block returns the transactions of the block which are sent by the tracked addresses
+func (m *WalletMetric) fetchL1Block(ctx context.Context, number uint64) ([]l1Tx, error) {
+	block, err := m.l1rpc.BlockByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return nil, err
+	}
+
+	var txs []l1Tx
+	for i, tx := range block.Transactions() {
+		from, err := m.l1rpc.TransactionSender(ctx, tx, block.Hash(), uint(i))
+		if err != nil {
+			return nil, fmt.Errorf("get sender of %s: %s", tx.Hash(), err)
+		}
+
+		m.mutex.Lock()
+		alias, ok := m.txs.senders[from]
+		m.mutex.Unlock()
+		if !ok {
+			continue
+		}
+
+		receipt, err := m.l1rpc.TransactionReceipt(ctx, tx.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("get receipt of %s: %s", tx.Hash(), err)
+		}
+
+		txs = append(txs, l1Tx{alias: alias, from: from, tx: tx, receipt: receipt, blockTime: time.Unix(int64(block.Time()), 0)})
+	}
+	return txs, nil
+}
+
+func (m *WalletMetric) observeL1Tx(t l1Tx) {
+	alias, tx, receipt := t.alias, t.tx, t.receipt
+	labels := prometheus.Labels{"chain": "eth", "addr": t.from.Hex(), "alias": alias}
+	fee := txFee(receipt)
+
+	m.txs.sent.With(labels).Inc()
+	m.txs.fees.With(labels).Add(utils.ToEther(fee))
+	if receipt.Status == types.ReceiptStatusFailed {
+		m.txs.reverted.With(labels).Inc()
+		m.logger.Warn("reverted transaction", "chain", "eth", "alias", alias, "tx", tx.Hash(), "block", receipt.BlockNumber)
+	}
+
+	if tx.Type() == types.BlobTxType {
+		m.txs.blobTxs.With(labels).Inc()
+		m.txs.blobs.With(labels).Add(float64(len(tx.BlobHashes())))
+		m.txs.blobGasUsed.With(labels).Add(float64(receipt.BlobGasUsed))
+		if receipt.BlobGasPrice != nil {
+			blobFee := new(big.Int).Mul(new(big.Int).SetUint64(receipt.BlobGasUsed), receipt.BlobGasPrice)
+			m.txs.blobFees.With(labels).Add(utils.ToEther(blobFee))
+		}
+		m.mutex.Lock()
+		if last, ok := m.txs.lastBlobAt[alias]; !ok || t.blockTime.After(last) {
+			m.txs.lastBlobAt[alias] = t.blockTime
+		}
+		m.mutex.Unlock()
+	}
+
+	m.logger.Debug("transaction", "chain", "eth", "alias", alias, "tx", tx.Hash(), "type", tx.Type(), "status", receipt.Status, "fee", utils.ToEther(fee))
+}
+
+// txFee returns the fee in wei paid for the transaction, blob fee included
+func txFee(receipt *types.Receipt) *big.Int {
+	fee := new(big.Int)
+	if receipt.EffectiveGasPrice != nil {
+		fee.Mul(new(big.Int).SetUint64(receipt.GasUsed), receipt.EffectiveGasPrice)
+	}
+	if receipt.BlobGasPrice != nil {
+		fee.Add(fee, new(big.Int).Mul(new(big.Int).SetUint64(receipt.BlobGasUsed), receipt.BlobGasPrice))
+	}
+	return fee
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/collector"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/ethrpc"
+	"github.com/metis-devops/metis-sequencer-exporter/internal/themis"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTxFee(t *testing.T) {
+	tests := []struct {
+		name    string
+		receipt *types.Receipt
+		want    *big.Int
+	}{
+		{
+			name:    "legacy",
+			receipt: &types.Receipt{GasUsed: 21000, EffectiveGasPrice: big.NewInt(1e9)},
+			want:    big.NewInt(21000 * 1e9),
+		},
+		{
+			name: "blob",
+			receipt: &types.Receipt{
+				GasUsed:           21000,
+				EffectiveGasPrice: big.NewInt(1e9),
+				BlobGasUsed:       131072,
+				BlobGasPrice:      big.NewInt(10),
+			},
+			want: big.NewInt(21000*1e9 + 131072*10),
+		},
+		{
+			name:    "no-price",
+			receipt: &types.Receipt{GasUsed: 21000},
+			want:    big.NewInt(0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := txFee(tt.receipt); got.Cmp(tt.want) != 0 {
+				t.Errorf("txFee() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeL1Geth is a json-rpc server of the blocks of the transactions sent by the key,
+// the receipts of the failing transactions return an error until their failures are used up
+type fakeL1Geth struct {
+	t        *testing.T
+	head     uint64
+	blocks   map[uint64][]*types.Transaction
+	from     common.Address
+	failures map[common.Hash]int
+	mutex    sync.Mutex
+}
+
+// header returns the header of the block which is mined an hour after its number
+func (f *fakeL1Geth) header(number uint64) *types.Header {
+	header := &types.Header{Number: new(big.Int).SetUint64(number), Difficulty: common.Big0, Time: number * 3600}
+	if len(f.blocks[number]) > 0 {
+		// the client checks only if the root is empty or not
+		header.TxHash = f.blocks[number][0].Hash()
+	} else {
+		header.TxHash = types.EmptyTxsHash
+	}
+	header.UncleHash = types.EmptyUncleHash
+	return header
+}
+
+// marshal merges the json object of the value with the fields
+func (f *fakeL1Geth) marshal(value any, fields map[string]any) map[string]any {
+	data, err := json.Marshal(value)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	var object map[string]any
+	if err := json.Unmarshal(data, &object); err != nil {
+		f.t.Fatal(err)
+	}
+	maps.Copy(object, fields)
+	return object
+}
+
+func (f *fakeL1Geth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		f.t.Errorf("invalid request: %s", err)
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	res := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+	switch req.Method {
+	case "eth_blockNumber":
+		res["result"] = hexutil.Uint64(f.head)
+	case "eth_getBlockByNumber":
+		var number hexutil.Uint64
+		if err := json.Unmarshal(req.Params[0], &number); err != nil {
+			f.t.Errorf("invalid block number %s: %s", req.Params[0], err)
+		}
+		header := f.header(uint64(number))
+		var txs []any
+		for i, tx := range f.blocks[uint64(number)] {
+			txs = append(txs, f.marshal(tx, map[string]any{
+				"from":             f.from,
+				"blockHash":        header.Hash(),
+				"blockNumber":      number,
+				"transactionIndex": hexutil.Uint64(i),
+			}))
+		}
+		res["result"] = f.marshal(header, map[string]any{"hash": header.Hash(), "transactions": txs, "uncles": []any{}})
+	case "eth_getTransactionReceipt":
+		var hash common.Hash
+		if err := json.Unmarshal(req.Params[0], &hash); err != nil {
+			f.t.Errorf("invalid tx hash %s: %s", req.Params[0], err)
+		}
+		if f.failures[hash] > 0 {
+			f.failures[hash]--
+			res["error"] = map[string]any{"code": -32000, "message": "receipt is not available"}
+			break
+		}
+		receipt := &types.Receipt{
+			Status:            types.ReceiptStatusSuccessful,
+			TxHash:            hash,
+			GasUsed:           21000,
+			EffectiveGasPrice: big.NewInt(1e9),
+			Logs:              []*types.Log{},
+		}
+		for _, txs := range f.blocks {
+			for _, tx := range txs {
+				if tx.Hash() == hash && tx.Type() == types.BlobTxType {
+					receipt.Type = types.BlobTxType
+					receipt.BlobGasUsed = tx.BlobGas()
+					receipt.BlobGasPrice = big.NewInt(1)
+				}
+			}
+		}
+		res["result"] = receipt
+	default:
+		f.t.Errorf("unexpected method %s", req.Method)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// newTestTx returns a signed transaction, a blob one if it has blobs
+func newTestTx(t *testing.T, key *ecdsa.PrivateKey, nonce uint64, blobs int) *types.Transaction {
+	signer := types.NewCancunSigner(big.NewInt(1))
+	to := common.HexToAddress("0x01")
+	if blobs == 0 {
+		return types.MustSignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID: big.NewInt(1), Nonce: nonce, Gas: 21000, GasFeeCap: big.NewInt(1e9), GasTipCap: big.NewInt(1), To: &to,
+		})
+	}
+	hashes := make([]common.Hash, blobs)
+	for i := range hashes {
+		hashes[i] = common.Hash{0x01, byte(i)}
+	}
+	return types.MustSignNewTx(key, signer, &types.BlobTx{
+		ChainID: uint256.NewInt(1), Nonce: nonce, Gas: 21000, GasFeeCap: uint256.NewInt(1e9), GasTipCap: uint256.NewInt(1),
+		To: to, BlobFeeCap: uint256.NewInt(1), BlobHashes: hashes,
+	})
+}
+
+// newTestWalletTxs returns the wallet metric of the l1 server which tracks the transactions sent by the alias
+func newTestWalletTxs(t *testing.T, l1 *fakeL1Geth, alias string) *WalletMetric {
+	server := httptest.NewServer(l1)
+	t.Cleanup(server.Close)
+	m := &WalletMetric{
+		l1rpc:  ethrpc.New(server.URL),
+		txs:    newWalletTxsMetric(prometheus.NewRegistry(), map[string]common.Address{alias: l1.from}),
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	t.Cleanup(m.l1rpc.Close)
+	return m
+}
+
+func TestWalletMetric_ScrapeL1Txs(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// failures is the number of the failed receipts of the second transaction
+		failures int
+		wantErrs int
+		wantSent float64
+		wantFees float64
+		wantLast uint64
+	}{
+		{
+			name:     "ok",
+			wantSent: 2,
+			wantFees: 2 * 21000 * 1e9 / 1e18,
+			wantLast: 11,
+		},
+		{
+			name:     "failed receipt",
+			failures: 1,
+			wantErrs: 1,
+			wantSent: 2,
+			wantFees: 2 * 21000 * 1e9 / 1e18,
+			wantLast: 11,
+		},
+		{
+			name:     "failed receipts",
+			failures: 3,
+			wantErrs: 3,
+			wantSent: 0,
+			wantLast: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txs := []*types.Transaction{newTestTx(t, key, 0, 0), newTestTx(t, key, 1, 0)}
+			l1 := &fakeL1Geth{
+				t:        t,
+				head:     11,
+				blocks:   map[uint64][]*types.Transaction{11: txs},
+				from:     crypto.PubkeyToAddress(key.PublicKey),
+				failures: map[common.Hash]int{txs[1].Hash(): tt.failures},
+			}
+			m := newTestWalletTxs(t, l1, "custom")
+			m.txs.lastBlock = 10
+
+			var errs int
+			for i := 0; i < 3; i++ {
+				if err := m.scrapeL1Txs(context.Background(), collector.Target{}); err != nil {
+					errs++
+				}
+			}
+			if errs != tt.wantErrs {
+				t.Errorf("errors = %d, want %d", errs, tt.wantErrs)
+			}
+
+			labels := prometheus.Labels{"chain": "eth", "addr": l1.from.Hex(), "alias": "custom"}
+			if got := testutil.ToFloat64(m.txs.sent.With(labels)); got != tt.wantSent {
+				t.Errorf("tx_sent = %v, want %v", got, tt.wantSent)
+			}
+			if got := testutil.ToFloat64(m.txs.fees.With(labels)); math.Abs(got-tt.wantFees) > 1e-12 {
+				t.Errorf("tx_fees = %v, want %v", got, tt.wantFees)
+			}
+			if m.txs.lastBlock != tt.wantLast {
+				t.Errorf("last block = %d, want %d", m.txs.lastBlock, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestWalletMetric_ScrapeL1Txs_Blobs(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blobTx := newTestTx(t, key, 1, 2)
+	l1 := &fakeL1Geth{
+		t:      t,
+		head:   10,
+		blocks: map[uint64][]*types.Transaction{11: {newTestTx(t, key, 0, 0), blobTx}},
+		from:   crypto.PubkeyToAddress(key.PublicKey),
+	}
+	alias := themis.BlobSubmitMpcAddr.String()
+	m := newTestWalletTxs(t, l1, alias)
+	m.txs.lastBlock = 10
+
+	// the blob age is unknown until the first submission
+	if err := m.scrapeL1Txs(context.Background(), collector.Target{}); err != nil {
+		t.Fatalf("scrapeL1Txs() error = %v", err)
+	}
+	if got := testutil.CollectAndCount(m.txs.blobAge); got != 0 {
+		t.Errorf("blob age series = %d, want 0 before the first submission", got)
+	}
+
+	l1.mutex.Lock()
+	l1.head = 11
+	l1.mutex.Unlock()
+	if err := m.scrapeL1Txs(context.Background(), collector.Target{}); err != nil {
+		t.Fatalf("scrapeL1Txs() error = %v", err)
+	}
+
+	labels := prometheus.Labels{"chain": "eth", "addr": l1.from.Hex(), "alias": alias}
+	tests := []struct {
+		name   string
+		metric prometheus.Collector
+		want   float64
+	}{
+		{name: "tx_sent", metric: m.txs.sent.With(labels), want: 2},
+		{name: "blob_tx_sent", metric: m.txs.blobTxs.With(labels), want: 1},
+		{name: "blobs", metric: m.txs.blobs.With(labels), want: 2},
+		{name: "blob_gas_used", metric: m.txs.blobGasUsed.With(labels), want: float64(blobTx.BlobGas())},
+		{name: "blob_fees", metric: m.txs.blobFees.With(labels), want: float64(blobTx.BlobGas()) / 1e18},
+	}
+	for _, tt := range tests {
+		if got := testutil.ToFloat64(tt.metric); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// the block 11 is mined at 11 hours after the epoch
+	want := time.Since(time.Unix(11*3600, 0)).Seconds()
+	if got := testutil.ToFloat64(m.txs.blobAge.With(labels)); math.Abs(got-want) > 5 {
+		t.Errorf("blob_age = %v, want %v", got, want)
+	}
+}
+
+func TestWalletMetric_ObserveL1Tx_Blob(t *testing.T) {
+	from := common.HexToAddress("0x01")
+	m := &WalletMetric{
+		txs:    newWalletTxsMetric(prometheus.NewRegistry(), map[string]common.Address{"Custom": from}),
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	labels := prometheus.Labels{"chain": "eth", "addr": from.Hex(), "alias": "Custom"}
+
+	blockTime := time.Now().Add(-time.Hour)
+	blobTx := types.NewTx(&types.BlobTx{BlobHashes: []common.Hash{{1}, {2}}})
+	blobReceipt := &types.Receipt{
+		Status:            types.ReceiptStatusSuccessful,
+		GasUsed:           21000,
+		EffectiveGasPrice: big.NewInt(1e9),
+		BlobGasUsed:       262144,
+		BlobGasPrice:      big.NewInt(1e9),
+	}
+	m.observeL1Tx(l1Tx{alias: "Custom", from: from, tx: blobTx, receipt: blobReceipt, blockTime: blockTime})
+
+	// the later legacy transaction isn't a blob submission
+	legacyTx := types.NewTx(&types.LegacyTx{})
+	legacyReceipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: 21000, EffectiveGasPrice: big.NewInt(1e9)}
+	m.observeL1Tx(l1Tx{alias: "Custom", from: from, tx: legacyTx, receipt: legacyReceipt, blockTime: blockTime.Add(time.Minute)})
+	m.txs.updateBlobAge()
+
+	if got := testutil.ToFloat64(m.txs.sent.With(labels)); got != 2 {
+		t.Errorf("tx_sent = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.txs.blobTxs.With(labels)); got != 1 {
+		t.Errorf("blob_tx_sent = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.txs.blobs.With(labels)); got != 2 {
+		t.Errorf("blobs = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.txs.blobGasUsed.With(labels)); got != 262144 {
+		t.Errorf("blob_gas_used = %v, want 262144", got)
+	}
+	if got, want := testutil.ToFloat64(m.txs.blobFees.With(labels)), 262144*1e9/1e18; got != want {
+		t.Errorf("blob_fees = %v, want %v", got, want)
+	}
+	if got := testutil.ToFloat64(m.txs.blobAge.With(labels)); got < 3600 || got >= 3660 {
+		t.Errorf("blob_age = %v, want the age of the blob transaction", got)
+	}
+}